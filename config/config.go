@@ -1,10 +1,13 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/gopherd/doge/encoding/jsonx"
 	"github.com/gopherd/log"
@@ -58,13 +61,28 @@ func (mode *Mode) UnmarshalJSON(data []byte) error {
 type BasicConfig struct {
 	// source of config
 	source string `json:"-"`
+	// origins of values
+	origins Origins `json:"-"`
+	// original values of secrets by key paths
+	secrets map[string]string `json:"-"`
+	// overrides of environment variables and -set flags
+	overrides Overrides `json:"-"`
 
 	// Core represents core common fields
-	Core CoreConfig `json:"core"`
+	Core CoreConfig `json:"core" doc:"Core configuration"`
 }
 
-// Read implements Configurator Read method
+// Read implements Configurator Read method, the content is decoded as jsonx
+// after includes, overlay and secret placeholders resolved if r is passed by
+// the package level function Read.
 func (c *BasicConfig) Read(self Configurator, r io.Reader) error {
+	if sr, ok := r.(*sourceReader); ok {
+		data, err := sr.resolve()
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
 	return jsonx.NewDecoder(r,
 		jsonx.WithSupportComment(),
 		jsonx.WithSupportExtraComma(),
@@ -82,13 +100,69 @@ func (c BasicConfig) Write(self Configurator, w io.Writer) error {
 
 // Core configuration
 type CoreConfig struct {
-	Project   string          `json:"project"`
-	Mode      Mode            `json:"mode"`
-	Name      string          `json:"name"`
-	ID        int64           `json:"id"`
-	Log       LogConfig       `json:"log"`
-	MQ        MQConfig        `json:"mq"`
-	Discovery DiscoveryConfig `json:"discovery"`
+	Project   string          `json:"project" doc:"Project name"`
+	Mode      Mode            `json:"mode" doc:"Running mode" enum:"dev,preview,prod"`
+	Name      string          `json:"name" doc:"Service name"`
+	ID        int64           `json:"id" doc:"Service id, unique in the service name"`
+	Log       LogConfig       `json:"log" doc:"Log configuration"`
+	MQ        MQConfig        `json:"mq" doc:"Message queue configuration"`
+	Discovery DiscoveryConfig `json:"discovery" doc:"Discovery configuration"`
+	Loop      LoopConfig      `json:"loop" doc:"Frame loop configuration"`
+	Admin     AdminConfig     `json:"admin" doc:"Admin http server configuration"`
+	Module    ModuleConfig    `json:"module" doc:"Module lifecycle configuration"`
+
+	// DrainTimeout is the max seconds of waiting for busy service while stopping.
+	//  0: default 30 seconds
+	// -1: no limit
+	DrainTimeout int `json:"drain_timeout" doc:"Max seconds of waiting for busy service while stopping, 0: 30 seconds, -1: no limit"`
+}
+
+// GetDrainTimeout returns the max duration of waiting for busy service while
+// stopping, 0 returned if no limit
+func (c CoreConfig) GetDrainTimeout() time.Duration {
+	if c.DrainTimeout == 0 {
+		return 30 * time.Second
+	} else if c.DrainTimeout < 0 {
+		return 0
+	}
+	return time.Duration(c.DrainTimeout) * time.Second
+}
+
+// Validate implements Validator Validate method, configs which embed
+// BasicConfig should call it in their own Validate method.
+func (c *BasicConfig) Validate() error {
+	return c.Core.Validate()
+}
+
+// Validate validates values of core configuration
+func (c CoreConfig) Validate() error {
+	if c.Log.Level != "" {
+		if _, ok := log.ParseLevel(c.Log.Level); !ok {
+			return fmt.Errorf("core.log.level: unknown level %q", c.Log.Level)
+		}
+	}
+	if !c.MQ.Off && c.Discovery.Off {
+		return errors.New("core.discovery: discovery required if mq enabled")
+	}
+	if c.DrainTimeout < -1 {
+		return fmt.Errorf("core.drain_timeout: invalid value %d", c.DrainTimeout)
+	}
+	if c.Loop.FPS < 0 {
+		return fmt.Errorf("core.loop.fps: invalid value %d", c.Loop.FPS)
+	}
+	if c.Loop.SlowFrame < 0 {
+		return fmt.Errorf("core.loop.slow_frame: invalid value %d", c.Loop.SlowFrame)
+	}
+	if c.Module.InitTimeout < 0 {
+		return fmt.Errorf("core.module.init_timeout: invalid value %d", c.Module.InitTimeout)
+	}
+	if c.Module.StartTimeout < 0 {
+		return fmt.Errorf("core.module.start_timeout: invalid value %d", c.Module.StartTimeout)
+	}
+	if c.Module.ShutdownTimeout < 0 {
+		return fmt.Errorf("core.module.shutdown_timeout: invalid value %d", c.Module.ShutdownTimeout)
+	}
+	return nil
 }
 
 // GetSource implements Configurator GetSource method
@@ -101,6 +175,30 @@ func (c *BasicConfig) SetSource(source string) {
 	c.source = source
 }
 
+func (c *BasicConfig) getOrigins() Origins {
+	return c.origins
+}
+
+func (c *BasicConfig) setOrigins(origins Origins) {
+	c.origins = origins
+}
+
+func (c *BasicConfig) getSecrets() map[string]string {
+	return c.secrets
+}
+
+func (c *BasicConfig) setSecrets(secrets map[string]string) {
+	c.secrets = secrets
+}
+
+func (c *BasicConfig) getOverrides() Overrides {
+	return c.overrides
+}
+
+func (c *BasicConfig) setOverrides(overrides Overrides) {
+	c.overrides = overrides
+}
+
 // GetCore implements Configurator GetCore method
 func (c *BasicConfig) GetCore() *CoreConfig {
 	return &c.Core
@@ -117,21 +215,21 @@ func (c *BasicConfig) OnReload() {
 // LogConfig represents configuration of log
 type LogConfig struct {
 	// Prefix to preappend to each log message
-	Prefix string `json:"prefix"`
+	Prefix string `json:"prefix" doc:"Prefix of each log message"`
 	// Level of log, reload supported
-	Level string `json:"level"`
+	Level string `json:"level" doc:"Log level: trace, debug, info, warn, error or fatal, reload supported"`
 	// Flags of log printer, reload supported
 	// @see githug.com/gopherd/log@Flags.
 	// -1: no flags
 	//  0: default flags
-	Flags int `json:"flags"`
+	Flags int `json:"flags" doc:"Log flags, reload supported, 0: default flags, -1: no flags"`
 
 	// Writers specified multi-writers, like:
 	//	[
 	//		"console",
 	//		"file:path/to/filename?suffix=.txt"
 	//	]
-	Writers []string `json:"writers"`
+	Writers []string `json:"writers" doc:"Log writers, e.g. console, file:path/to/filename?suffix=.txt"`
 }
 
 func (cfg LogConfig) FixedFlags() int {
@@ -145,14 +243,39 @@ func (cfg LogConfig) FixedFlags() int {
 
 // MQConfig ...
 type MQConfig struct {
-	Off    bool   `json:"off"`
-	Name   string `json:"name"`
-	Source string `json:"source"`
+	Off    bool   `json:"off" doc:"Disable message queue"`
+	Name   string `json:"name" doc:"Driver name of message queue"`
+	Source string `json:"source" doc:"Driver source of message queue"`
 }
 
 // DiscoveryConfig ...
 type DiscoveryConfig struct {
-	Off    bool   `json:"off"`
-	Name   string `json:"name"`
-	Source string `json:"source"`
+	Off    bool   `json:"off" doc:"Disable discovery"`
+	Name   string `json:"name" doc:"Driver name of discovery"`
+	Source string `json:"source" doc:"Driver source of discovery"`
+}
+
+// LoopConfig represents configuration of the built-in frame loop
+type LoopConfig struct {
+	// FPS is frames per second of the loop, the loop is disabled if FPS <= 0
+	FPS int `json:"fps" doc:"Frames per second of the loop, the loop is disabled if fps <= 0"`
+	// SlowFrame is the threshold milliseconds of logging slow frames,
+	// the frame interval used if SlowFrame <= 0
+	SlowFrame int `json:"slow_frame" doc:"Threshold milliseconds of logging slow frames, the frame interval used if slow_frame <= 0"`
+}
+
+// ModuleConfig represents configuration of lifecycle of modules
+type ModuleConfig struct {
+	// InitTimeout is the max seconds of initializing modules, 0 means no limit
+	InitTimeout int `json:"init_timeout" doc:"Max seconds of initializing modules, 0: no limit"`
+	// StartTimeout is the max seconds of starting modules, 0 means no limit
+	StartTimeout int `json:"start_timeout" doc:"Max seconds of starting modules, 0: no limit"`
+	// ShutdownTimeout is the max seconds of shutting down modules, 0 means no limit
+	ShutdownTimeout int `json:"shutdown_timeout" doc:"Max seconds of shutting down modules, 0: no limit"`
+}
+
+// AdminConfig represents configuration of the admin http server
+type AdminConfig struct {
+	// Address to listen, the admin server is disabled if it's empty
+	Address string `json:"address" doc:"Address to listen, the admin server is disabled if it's empty"`
 }
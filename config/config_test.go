@@ -0,0 +1,614 @@
+package config_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/config"
+	_ "github.com/gopherd/doge/config/source/discoverysource"
+	_ "github.com/gopherd/doge/config/source/httpsource"
+	"github.com/gopherd/doge/crypto/cryptoutil"
+	discoverymemory "github.com/gopherd/doge/service/discovery/memory"
+)
+
+type testConfig struct {
+	config.BasicConfig
+	Name  string         `json:"name"`
+	Ports []int          `json:"ports"`
+	Extra map[string]int `json:"extra"`
+}
+
+func (c *testConfig) Default() config.Configurator {
+	return new(testConfig)
+}
+
+func TestDiff(t *testing.T) {
+	x := &testConfig{Name: "a", Ports: []int{1, 2}, Extra: map[string]int{"a": 1, "b": 2}}
+	y := &testConfig{Name: "a", Ports: []int{1, 3}, Extra: map[string]int{"a": 1, "c": 3}}
+	y.Core.Log.Level = "debug"
+	keys, err := config.Diff(x, y)
+	if err != nil {
+		t.Fatalf("diff error: %v", err)
+	}
+	want := []string{"core.log.level", "extra.b", "extra.c", "ports"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("want %v, got %v", want, keys)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "test.conf")
+	if err := os.WriteFile(source, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := config.Hash(context.Background(), source)
+	if err != nil || hash == "" {
+		t.Fatalf("hash error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := config.Watch(ctx, source, 10*time.Millisecond)
+	// make sure the modification time changed for polling
+	time.Sleep(20 * time.Millisecond)
+	if err := os.WriteFile(source, []byte(`{name: "x"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change not notified")
+	}
+	if newHash, _ := config.Hash(context.Background(), source); newHash == hash {
+		t.Errorf("hash not changed")
+	}
+	cancel()
+	for range changes {
+	}
+}
+
+type validatedConfig struct {
+	config.BasicConfig
+	Address string `json:"address"`
+}
+
+func (c *validatedConfig) Default() config.Configurator {
+	return new(validatedConfig)
+}
+
+func (c *validatedConfig) Validate() error {
+	if c.Address == "" {
+		return errors.New("address required")
+	}
+	return c.BasicConfig.Validate()
+}
+
+func TestWatchLinks(t *testing.T) {
+	// layout of Kubernetes ConfigMap volumes: app.conf -> ..data/app.conf, ..data -> ..v1
+	dir := t.TempDir()
+	for _, v := range []string{"..v1", "..v2"} {
+		if err := os.Mkdir(filepath.Join(dir, v), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, v, "app.conf"), []byte(`{name: "`+v+`"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "app.conf")
+	if err := os.Symlink(filepath.Join("..data", "app.conf"), source); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := config.Watch(ctx, source, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(dir, "other.conf"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+		t.Fatal("change of unwatched file notified")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// replace the ..data link by renaming
+	if err := os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change not notified")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "test.conf")
+	for _, tc := range []struct {
+		content string
+		valid   bool
+	}{
+		{`{address: ":80"}`, true},
+		{`{}`, false},
+		{`{address: ":80", core: {log: {level: "loud"}}}`, false},
+		{`{address: ":80", core: {module: {init_timeout: 10}}}`, true},
+		{`{address: ":80", core: {module: {shutdown_timeout: -1}}}`, false},
+	} {
+		if err := os.WriteFile(source, []byte(tc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg := new(validatedConfig)
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		err := config.Init(flagSet, cfg, config.WithDefaultSource(source))
+		if tc.valid {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tc.content, err)
+			}
+			continue
+		}
+		var verr *config.ValidationError
+		if code, ok := config.IsExitError(err); !ok || code != config.ExitCodeInvalid || !errors.As(err, &verr) {
+			t.Errorf("%s: want validation exit error, got %v", tc.content, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "test.conf")
+	content := `{name: "file", ports: [1], core: {id: 1, log: {level: "info"}}}`
+	if err := os.WriteFile(source, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_CORE_ID", "2")
+	t.Setenv("TEST_NAME", "env")
+	t.Setenv("TEST_PORTS", "[1,2]")
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"test", "-set", "name=flag", "-set", "core.log.level=debug", "-set", "extra={\"a\":1}"}
+
+	cfg := new(testConfig)
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	if err := config.Init(flagSet, cfg, config.WithDefaultSource(source), config.WithEnvPrefix("TEST_")); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "flag" || cfg.Core.ID != 2 || cfg.Core.Log.Level != "debug" ||
+		!reflect.DeepEqual(cfg.Ports, []int{1, 2}) || cfg.Extra["a"] != 1 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	origins := config.OriginsOf(cfg)
+	for path, want := range map[string]config.Layer{
+		"name":           config.LayerFlag,
+		"ports":          config.LayerEnv,
+		"core.id":        config.LayerEnv,
+		"core.log.level": config.LayerFlag,
+		"core.log.flags": config.LayerDefault,
+		"core.project":   config.LayerDefault,
+		"extra.a":        config.LayerFlag,
+	} {
+		if got := origins.Of(path); got != want {
+			t.Errorf("origin of %s: want %v, got %v", path, want, got)
+		}
+	}
+
+	os.Args = []string{"test", "-set", "core.id=x"}
+	flagSet = flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	if err := config.Init(flagSet, new(testConfig), config.WithDefaultSource(source)); err == nil {
+		t.Fatal("want error of invalid override")
+	}
+	for _, set := range []string{"core.lg.level=debug", "name"} {
+		other := new(testConfig)
+		other.SetSource(source)
+		config.SetOverrides(other, config.Overrides{Sets: []string{set}})
+		if _, err := config.Load(other, false); err == nil {
+			t.Errorf("%s: want error of invalid override", set)
+		}
+	}
+
+	// overrides are recorded per config
+	other := new(testConfig)
+	other.SetSource(source)
+	if _, err := config.Load(other, false); err != nil {
+		t.Fatal(err)
+	}
+	if other.Name != "file" || config.OriginsOf(other).Of("name") != config.LayerFile {
+		t.Errorf("config affected by overrides of others: %+v", other)
+	}
+}
+
+type schemaConfig struct {
+	config.BasicConfig
+	Host    string            `json:"host" doc:"Host of server" default:"localhost"`
+	Port    int               `json:"port,omitempty" doc:"Port of server" default:"8080"`
+	Codec   string            `json:"codec" enum:"json,proto"`
+	Weights map[string]uint   `json:"weights"`
+	Tags    []string          `json:"tags"`
+	Ignored string            `json:"-"`
+	Meta    map[string]string `json:"meta" doc:"Metadata\nof server"`
+}
+
+func (c *schemaConfig) Default() config.Configurator {
+	return &schemaConfig{Codec: "json"}
+}
+
+func TestSchema(t *testing.T) {
+	s, err := config.NewSchema(new(schemaConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"core", "host", "port", "codec", "weights", "tags", "meta"}; !reflect.DeepEqual(s.Keys(), want) {
+		t.Fatalf("keys: want %v, got %v", want, s.Keys())
+	}
+	for _, tc := range []struct {
+		schema *config.Schema
+		want   config.Schema
+	}{
+		{s.Properties["host"], config.Schema{Type: "string", Description: "Host of server", Default: "localhost"}},
+		{s.Properties["port"], config.Schema{Type: "integer", Description: "Port of server", Default: float64(8080)}},
+		{s.Properties["codec"], config.Schema{Type: "string", Default: "json", Enum: []any{"json", "proto"}}},
+		{s.Properties["core"].Properties["mode"], config.Schema{Type: "string", Description: "Running mode", Default: "dev", Enum: []any{"dev", "preview", "prod"}}},
+	} {
+		if !reflect.DeepEqual(*tc.schema, tc.want) {
+			t.Errorf("want %+v, got %+v", tc.want, *tc.schema)
+		}
+	}
+	if typ := s.Properties["weights"].AdditionalProperties.Type; typ != "integer" {
+		t.Errorf("type of weights: want integer, got %s", typ)
+	}
+	if typ := s.Properties["tags"].Items.Type; typ != "string" {
+		t.Errorf("type of tags: want string, got %s", typ)
+	}
+
+	var buf bytes.Buffer
+	if err := config.WriteTemplate(&buf, new(schemaConfig)); err != nil {
+		t.Fatal(err)
+	}
+	for _, comment := range []string{"// Host of server", "// Metadata\n", "// of server", `// Enum: ["json","proto"]`, "// Running mode"} {
+		if !strings.Contains(buf.String(), comment) {
+			t.Errorf("comment %q not found in template:\n%s", comment, buf.String())
+		}
+	}
+	cfg := new(schemaConfig)
+	if err := cfg.Read(cfg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "localhost" || cfg.Port != 8080 || cfg.Codec != "json" {
+		t.Errorf("unexpected config read from template: %+v", cfg)
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	write("shared/log.conf", `{include: "base.conf", core: {log: {level: "info", writers: ["console", "file:a.log"]}}}`)
+	write("shared/base.conf", `{name: "base", ports: [1, 2], core: {project: "doge", log: {prefix: "base"}}}`)
+	source := write("app/app.conf", `{
+		// included files are overridden by the including file
+		include: ["../shared/log.conf"],
+		core: {mode: "prod", log: {level: "debug"}},
+		ports: [3],
+	}`)
+	write("app/app.prod.conf", `{core: {log: {writers: ["console"]}}, extra: {a: 1}}`)
+	write("app/app.dev.conf", `{name: "dev"}`)
+
+	cfg := new(testConfig)
+	cfg.SetSource(source)
+	if err := config.Read(cfg, false); err != nil {
+		t.Fatal(err)
+	}
+	core := cfg.Core
+	if cfg.Name != "base" || core.Project != "doge" || core.Mode != config.Prod ||
+		core.Log.Prefix != "base" || core.Log.Level != "debug" ||
+		!reflect.DeepEqual(core.Log.Writers, []string{"console"}) ||
+		!reflect.DeepEqual(cfg.Ports, []int{3}) || cfg.Extra["a"] != 1 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	hash, err := config.Hash(context.Background(), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("shared/base.conf", `{name: "base2"}`)
+	if h, err := config.Hash(context.Background(), source); err != nil || h == hash {
+		t.Errorf("hash not changed after included file changed: %v", err)
+	}
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	for _, tc := range []struct {
+		env  string
+		args []string
+		want string
+	}{
+		{env: "dev", want: "dev"},
+		{env: "prod", args: []string{"-set", "core.mode=dev"}, want: "dev"},
+		{args: []string{"-set", `core.mode="dev"`}, want: "dev"},
+	} {
+		t.Setenv("DOGE_CORE_MODE", tc.env)
+		if tc.env == "" {
+			os.Unsetenv("DOGE_CORE_MODE")
+		}
+		os.Args = append([]string{"test"}, tc.args...)
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		cfg = new(testConfig)
+		if err := config.Init(flagSet, cfg, config.WithDefaultSource(source)); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Name != tc.want || cfg.Core.Mode != config.Dev {
+			t.Errorf("env %q, args %q: want overlay of mode %s, got config %+v", tc.env, tc.args, tc.want, cfg)
+		}
+	}
+	write("shared/base.conf", `{include: "../app/app.conf"}`)
+	cfg = new(testConfig)
+	cfg.SetSource(source)
+	if err := config.Read(cfg, false); !errors.Is(err, config.ErrIncludeCycle) {
+		t.Errorf("want include cycle error, got %v", err)
+	}
+}
+
+// lineConfig reads config from lines of key=value
+type lineConfig struct {
+	config.BasicConfig
+	Values map[string]string
+}
+
+func (c *lineConfig) Default() config.Configurator {
+	return new(lineConfig)
+}
+
+func (c *lineConfig) Read(self config.Configurator, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	c.Values = make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("invalid line %q", line)
+		}
+		c.Values[k] = v
+	}
+	return nil
+}
+
+func TestReadCustom(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "app.conf")
+	if err := os.WriteFile(source, []byte("v=hello\nw=${env:NAME}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := new(lineConfig)
+	cfg.SetSource(source)
+	if err := config.Read(cfg, false); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"v": "hello", "w": "${env:NAME}"}
+	if !reflect.DeepEqual(cfg.Values, want) {
+		t.Errorf("want %v, got %v", want, cfg.Values)
+	}
+}
+
+func TestSource(t *testing.T) {
+	d := discoverymemory.Open("config_test")
+	ctx := context.Background()
+	if err := d.Register(ctx, "config.gate", "1", `{name: "discovery", core: {id: 1}}`, false, 0); err != nil {
+		t.Fatal(err)
+	}
+	source := "discovery://config.gate/1?driver=memory&source=config_test"
+	cfg := new(testConfig)
+	cfg.SetSource(source)
+	if err := config.Read(cfg, false); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "discovery" || cfg.Core.ID != 1 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	changes := config.Watch(watchCtx, source, time.Hour)
+	hash, _ := config.Hash(ctx, source)
+	d.Register(ctx, "config.gate", "1", `{name: "updated"}`, false, 0)
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("change of discovery source not pushed")
+	}
+	if h, _ := config.Hash(ctx, source); h == hash {
+		t.Error("hash not changed")
+	}
+
+	cfg = new(testConfig)
+	cfg.SetSource("discovery://config.gate/2?driver=memory&source=config_test")
+	if err := config.Read(cfg, true); err != nil {
+		t.Errorf("optional source: unexpected error: %v", err)
+	}
+	if err := config.Read(cfg, false); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("want not exist error, got %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/gate.conf" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `{"name": "http"}`)
+	}))
+	defer server.Close()
+	cfg = new(testConfig)
+	cfg.SetSource(server.URL + "/gate.conf")
+	if err := config.Read(cfg, false); err != nil || cfg.Name != "http" {
+		t.Errorf("unexpected config %+v read from http source, error: %v", cfg, err)
+	}
+	cfg.SetSource(server.URL + "/missing.conf")
+	if err := config.Read(cfg, true); err != nil {
+		t.Errorf("optional source: unexpected error: %v", err)
+	}
+
+	cfg.SetSource("unknown://a/b")
+	if err := config.Read(cfg, true); err == nil {
+		t.Error("want error of unknown scheme")
+	}
+
+	// driver and source of discovery from env and -set overrides
+	args := os.Args
+	defer func() { os.Args = args }()
+	t.Setenv("DOGE_CORE_DISCOVERY_NAME", "memory")
+	os.Args = []string{"test", "-c", "discovery://config.gate/1", "-set", "core.discovery.source=config_test"}
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	cfg = new(testConfig)
+	if err := config.Init(flagSet, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "updated" || cfg.Core.Discovery.Name != "memory" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	os.Unsetenv("DOGE_CORE_DISCOVERY_NAME")
+	os.Args = []string{"test", "-c", "discovery://config.gate/1"}
+	flagSet = flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.SetOutput(io.Discard)
+	if err := config.Init(flagSet, new(testConfig)); err == nil {
+		t.Fatal("want error of discovery source without driver")
+	}
+}
+
+func TestSecret(t *testing.T) {
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key.pem")
+	pubFile := filepath.Join(dir, "key.pub")
+	if err := cryptoutil.GenerateRSAPemFile(key, keyFile, pubFile); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cryptoutil.LoadRSAPublicKeyFile(pubFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := config.EncryptSecret(pub, "encrypted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretFile := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secretFile, []byte("from file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SECRET", "from env")
+	source := filepath.Join(dir, "test.conf")
+	content := `{
+		name: "u:${env:TEST_SECRET}@${file:` + secretFile + `}",
+		core: {project: "` + enc + `", log: {prefix: "$${env:TEST_SECRET}"}},
+	}`
+	if err := os.WriteFile(source, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config.SetSecretKey(nil)
+	cfg := new(testConfig)
+	cfg.SetSource(source)
+	if err := config.Read(cfg, false); !errors.Is(err, config.ErrSecretKeyRequired) {
+		t.Fatalf("want secret key required error, got %v", err)
+	}
+	if err := config.LoadSecretKey(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	defer config.SetSecretKey(nil)
+	if err := config.Read(cfg, false); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "u:from env@from file" || cfg.Core.Project != "encrypted" || cfg.Core.Log.Prefix != "${env:TEST_SECRET}" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if _, ok := config.SecretsOf(cfg)["core.log.prefix"]; ok {
+		t.Errorf("escaped value recorded as secret: %v", config.SecretsOf(cfg))
+	}
+
+	masked, err := config.Mask(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := cfg.Write(masked, &buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"from env", "from file", "encrypted"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("secret %q not masked: %s", secret, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), "$${env:TEST_SECRET}") {
+		t.Errorf("escaped value not escaped by Mask: %s", buf.String())
+	}
+	// masked config could be read again
+	if err := os.WriteFile(source, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reread := new(testConfig)
+	reread.SetSource(source)
+	if err := config.Read(reread, false); err != nil {
+		t.Fatal(err)
+	}
+	if reread.Name != cfg.Name || reread.Core.Project != cfg.Core.Project || reread.Core.Log.Prefix != cfg.Core.Log.Prefix {
+		t.Errorf("unexpected config read from masked config: %+v", reread)
+	}
+
+	// secret key loaded by Init from the flag or the environment variable
+	args := os.Args
+	defer func() { os.Args = args }()
+	for _, tc := range []struct {
+		env  string
+		args []string
+	}{
+		{args: []string{"-secret-key", keyFile}},
+		{env: keyFile},
+	} {
+		config.SetSecretKey(nil)
+		t.Setenv("DOGE_SECRET_KEY_FILE", tc.env)
+		os.Args = append([]string{"test"}, tc.args...)
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+		cfg := new(testConfig)
+		if err := config.Init(flagSet, cfg, config.WithDefaultSource(source)); err != nil {
+			t.Fatalf("env %q, args %q: %v", tc.env, tc.args, err)
+		}
+		if cfg.Core.Project != "encrypted" {
+			t.Errorf("env %q, args %q: unexpected config %+v", tc.env, tc.args, cfg)
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package config
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 
 	"github.com/gopherd/doge/build"
@@ -34,6 +38,12 @@ type option struct {
 	flagInput, usageInput     string
 	flagOutput, usageOutput   string
 	flagVersion, usageVersion string
+	flagSet, usageSet         string
+	flagSchema, usageSchema   string
+	flagTmpl, usageTmpl       string
+	flagSecret, usageSecret   string
+	envPrefix                 string
+	secretKey                 string
 }
 
 func newOption() *option {
@@ -43,6 +53,15 @@ func newOption() *option {
 		usageOutput:  "Exported config filename",
 		flagVersion:  "v",
 		usageVersion: "Print version information",
+		flagSet:      "set",
+		usageSet:     "Override config value by key path, e.g. -set core.log.level=debug, repeatable",
+		flagSchema:   "schema",
+		usageSchema:  "Exported JSON Schema filename of config",
+		flagTmpl:     "template",
+		usageTmpl:    "Exported config template filename",
+		flagSecret:   "secret-key",
+		usageSecret:  "RSA private key PEM file to decrypt ${enc:...} placeholders of config",
+		envPrefix:    "DOGE_",
 	}
 }
 
@@ -104,38 +123,168 @@ func WithVersion(flag, usage string) Option {
 	}
 }
 
+// WithSet specify command line flag name and usage for overriding config values
+func WithSet(flag, usage string) Option {
+	return func(opt *option) {
+		opt.flagSet = flag
+		opt.usageSet = usage
+	}
+}
+
+// WithSchema specify command line flag name and usage for JSON Schema output
+func WithSchema(flag, usage string) Option {
+	return func(opt *option) {
+		opt.flagSchema = flag
+		opt.usageSchema = usage
+	}
+}
+
+// WithTemplate specify command line flag name and usage for config template output
+func WithTemplate(flag, usage string) Option {
+	return func(opt *option) {
+		opt.flagTmpl = flag
+		opt.usageTmpl = usage
+	}
+}
+
+// WithSecretKeyFlag specify command line flag name and usage for the secret key file
+func WithSecretKeyFlag(flag, usage string) Option {
+	return func(opt *option) {
+		opt.flagSecret = flag
+		opt.usageSecret = usage
+	}
+}
+
+// WithSecretKey specify the default RSA private key PEM file used to decrypt
+// ${enc:...} placeholders, see LoadSecretKey. The file is overridden by the
+// command line flag (-secret-key by default) or the environment variable
+// EnvName(prefix, "secret_key_file"), e.g. DOGE_SECRET_KEY_FILE.
+func WithSecretKey(filename string) Option {
+	return func(opt *option) {
+		opt.secretKey = filename
+	}
+}
+
+// WithEnvPrefix specify prefix of environment variables for overriding config
+// values, "DOGE_" by default. Environment variables are ignored if prefix is empty.
+func WithEnvPrefix(prefix string) Option {
+	return func(opt *option) {
+		opt.envPrefix = prefix
+	}
+}
+
 type exitError struct {
 	code int
+	err  error // cause of exiting, maybe nil
 }
 
 func (e exitError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("exit with code %d: %v", e.code, e.err)
+	}
 	return fmt.Sprintf("exit with code %d", e.code)
 }
 
+func (e exitError) Unwrap() error {
+	return e.err
+}
+
+// ExitError returns an error which represents exiting process with code
+func ExitError(code int) error {
+	return exitError{code: code}
+}
+
+// ExitCodeInvalid is the exit code in case of config validation failed at startup
+const ExitCodeInvalid = 3
+
+// IsExitError reports whether the err is an exit error and returns the exit code
 func IsExitError(err error) (code int, ok bool) {
-	if err == nil {
-		return 0, false
-	}
-	for {
-		if e, ok := err.(exitError); ok {
-			return e.code, ok
-		}
-		if err = errors.Unwrap(err); err == nil {
-			return 0, false
-		}
+	var e exitError
+	if errors.As(err, &e) {
+		return e.code, true
 	}
+	return 0, false
 }
 
+// Read reads config from the source of cfg, see Source for supported
+// sources. The raw content of the source is passed to Configurator.Read, while
+// BasicConfig.Read decodes the resolved document instead: files included by
+// a file source and the overlay of the running mode are merged, and then
+// secret placeholders like ${env:NAME} are resolved, see IncludeKey, Overlay
+// and Mask. It does nothing if the source not found and optional is true.
 func Read(cfg Configurator, optional bool) error {
-	f, err := os.Open(cfg.GetSource())
+	src, u, filename, err := remoteSource(cfg.GetSource())
 	if err != nil {
-		if !optional || !os.IsNotExist(err) {
+		return err
+	}
+	var r *sourceReader
+	if src != nil {
+		data, err := readRemote(NewContext(context.Background(), cfg), src, u)
+		if err != nil {
+			if optional && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		r = newSourceReader(data, func() (any, error) {
+			doc, err := parse(data)
+			if err != nil {
+				return nil, fmt.Errorf("config: %s: %w", u.Redacted(), err)
+			}
+			return doc, nil
+		})
+	} else {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			if optional && os.IsNotExist(err) {
+				return nil
+			}
 			return err
 		}
-		return nil
+		mode, overrides := cfg.GetCore().Mode, OverridesOf(cfg)
+		r = newSourceReader(data, func() (any, error) {
+			return resolve(filename, mode, overrides)
+		})
 	}
-	defer f.Close()
-	return cfg.Read(cfg, f)
+	if err := cfg.Read(cfg, r); err != nil {
+		return err
+	}
+	setSecrets(cfg, r.secrets)
+	return nil
+}
+
+// sourceReader reads the raw content of a config source, BasicConfig.Read
+// decodes the document resolved by it instead.
+type sourceReader struct {
+	*bytes.Reader
+	resolveDoc func() (any, error)
+	secrets    map[string]string
+}
+
+func newSourceReader(data []byte, resolveDoc func() (any, error)) *sourceReader {
+	return &sourceReader{
+		Reader:     bytes.NewReader(data),
+		resolveDoc: resolveDoc,
+	}
+}
+
+// resolve returns the resolved document with secret placeholders resolved
+// as JSON
+func (r *sourceReader) resolve() ([]byte, error) {
+	doc, err := r.resolveDoc()
+	if err != nil {
+		return nil, err
+	}
+	secrets := make(map[string]string)
+	if doc, err = resolveSecrets(doc, "", secrets); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	r.secrets = secrets
+	return data, nil
 }
 
 // Init initializes Configure cfg from command line flags with options
@@ -145,16 +294,50 @@ func Init(flagSet *flag.FlagSet, cfg Configurator, options ...Option) error {
 
 	var (
 		input, output      string
+		schema, tmpl       string
+		secretKey          string
 		shouldPrintVersion bool
+		sets               []string
 	)
 	flagSet.StringVar(&input, opt.flagInput, "", opt.getUsageInput())
 	flagSet.StringVar(&output, opt.flagOutput, "", opt.getUsageOutput())
 	flagSet.BoolVar(&shouldPrintVersion, opt.flagVersion, false, opt.getUsageVersion())
+	flagSet.Var(setFlag{&sets}, opt.flagSet, opt.usageSet)
+	flagSet.StringVar(&schema, opt.flagSchema, "", opt.usageSchema)
+	flagSet.StringVar(&tmpl, opt.flagTmpl, "", opt.usageTmpl)
+	flagSet.StringVar(&secretKey, opt.flagSecret, "", opt.usageSecret)
 	flagSet.Parse(os.Args[1:])
 
+	SetOverrides(cfg, Overrides{EnvPrefix: opt.envPrefix, Sets: sets})
+
 	if shouldPrintVersion {
 		build.Print()
-		return exitError{0}
+		return exitError{code: 0}
+	}
+	if schema != "" || tmpl != "" {
+		if schema != "" {
+			if err := writeFile(schema, cfg, WriteSchema); err != nil {
+				return err
+			}
+		}
+		if tmpl != "" {
+			if err := writeFile(tmpl, cfg, WriteTemplate); err != nil {
+				return err
+			}
+		}
+		return exitError{code: 0}
+	}
+
+	if secretKey == "" && opt.envPrefix != "" {
+		secretKey = os.Getenv(EnvName(opt.envPrefix, "secret_key_file"))
+	}
+	if secretKey == "" {
+		secretKey = opt.secretKey
+	}
+	if secretKey != "" {
+		if err := LoadSecretKey(secretKey); err != nil {
+			return err
+		}
 	}
 
 	var optional = false
@@ -164,7 +347,7 @@ func Init(flagSet *flag.FlagSet, cfg Configurator, options ...Option) error {
 	}
 
 	cfg.SetSource(input)
-	if err := Read(cfg, optional); err != nil {
+	if _, err := Load(cfg, optional); err != nil {
 		return err
 	}
 
@@ -172,20 +355,71 @@ func Init(flagSet *flag.FlagSet, cfg Configurator, options ...Option) error {
 		if outputFile, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666); err != nil {
 			return err
 		} else {
-			err := cfg.Write(cfg, outputFile)
+			masked, err := Mask(cfg)
+			if err == nil {
+				err = cfg.Write(masked, outputFile)
+			}
 			outputFile.Close()
 			if err != nil {
 				return err
 			}
 		}
-		return exitError{0}
+		return exitError{code: 0}
+	}
+
+	if err := Validate(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitError{code: ExitCodeInvalid, err: err}
+	}
+	return nil
+}
+
+func writeFile(filename string, cfg Configurator, write func(io.Writer, Configurator) error) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	err = write(f, cfg)
+	if e := f.Close(); err == nil {
+		err = e
 	}
+	return err
+}
+
+// Validator is an optional interface which could be implemented by Configurator
+// to validate values of config. It's called at Init and before a reloaded config
+// applied.
+type Validator interface {
+	Validate() error
+}
 
+// ValidationError represents an error returned by Validator
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return "config: invalid config: " + e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Validate validates cfg if it implements Validator, the error is wrapped
+// as a *ValidationError.
+func Validate(cfg Configurator) error {
+	if v, ok := cfg.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return &ValidationError{Err: err}
+		}
+	}
 	return nil
 }
 
 // Discoverable represents a discoverable configurator
 type Discoverable interface {
-	// DiscoveredContent returns a discovered data
+	// DiscoveredContent returns a discovered data, secrets should be
+	// masked, see Mask
 	DiscoveredContent() any
 }
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Diff returns sorted paths of keys whose values differ between the JSON
+// encodings of old and new config, e.g. "core.log.level". Arrays are compared
+// as a whole.
+func Diff(old, new Configurator) ([]string, error) {
+	x, err := toJSONValue(old)
+	if err != nil {
+		return nil, err
+	}
+	y, err := toJSONValue(new)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	diff("", x, y, &keys)
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func toJSONValue(cfg Configurator) (any, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	err = json.Unmarshal(data, &v)
+	return v, err
+}
+
+func diff(path string, x, y any, keys *[]string) {
+	mx, ok1 := x.(map[string]any)
+	my, ok2 := y.(map[string]any)
+	if !ok1 || !ok2 {
+		if !reflect.DeepEqual(x, y) {
+			*keys = append(*keys, path)
+		}
+		return
+	}
+	join := func(k string) string {
+		if path == "" {
+			return k
+		}
+		return path + "." + k
+	}
+	for k, vx := range mx {
+		if vy, ok := my[k]; ok {
+			diff(join(k), vx, vy, keys)
+		} else {
+			*keys = append(*keys, join(k))
+		}
+	}
+	for k := range my {
+		if _, ok := mx[k]; !ok {
+			*keys = append(*keys, join(k))
+		}
+	}
+}
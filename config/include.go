@@ -0,0 +1,233 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gopherd/doge/encoding/jsonx"
+)
+
+// IncludeKey is the key of the include directive of config files. The value
+// is a path or an array of paths of files to include, relative paths are
+// resolved relative to the directory of the including file, e.g.
+//
+//	{
+//		include: ["../shared/log.conf", "../shared/discovery.conf"],
+//		core: {name: "gate"},
+//	}
+const IncludeKey = "include"
+
+// ErrIncludeCycle represents an error in case of files include each other
+var ErrIncludeCycle = errors.New("config: include cycle")
+
+// Modes are running modes of process
+var Modes = []Mode{Dev, Preview, Prod}
+
+// Overlay returns the mode-specific file of the config file source,
+// e.g. Overlay("etc/app.conf", Prod) returns "etc/app.prod.conf".
+func Overlay(source string, mode Mode) string {
+	name, _ := mode.MarshalJSON()
+	ext := filepath.Ext(source)
+	return strings.TrimSuffix(source, ext) + "." + strings.Trim(string(name), `"`) + ext
+}
+
+// resolve reads the config file source with its included files and the
+// overlay of mode, and merges them into one document. Rules of merging:
+//
+//   - Files included by a file are merged in order, and then the file itself
+//     is merged, so the later one overrides the former ones.
+//   - The overlay of mode is merged after the source. The mode is the
+//     effective core.mode: overrides of -set flags and environment variables,
+//     core.mode of the source, or defaultMode in order.
+//     The overlay is optional and could include other files, too.
+//   - Objects are merged key by key recursively.
+//   - Arrays, strings, numbers, booleans and nulls are replaced as a whole,
+//     so arrays are never concatenated.
+func resolve(source string, defaultMode Mode, overrides Overrides) (any, error) {
+	r := new(resolver)
+	doc, err := r.load(source)
+	if err != nil {
+		return nil, err
+	}
+	mode := defaultMode
+	if m, ok := lookup(doc, "core.mode"); ok {
+		data, _ := json.Marshal(m)
+		if err := mode.UnmarshalJSON(data); err != nil {
+			return nil, fmt.Errorf("config: %s: %w", source, err)
+		}
+	}
+	if s, ok := overrides.Lookup("core.mode"); ok {
+		data, _ := json.Marshal(parseValue(s, ""))
+		if err := mode.UnmarshalJSON(data); err != nil {
+			return nil, fmt.Errorf("config: override core.mode: %w", err)
+		}
+	}
+	overlay, err := r.load(Overlay(source, mode))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return doc, nil
+		}
+		return nil, err
+	}
+	return merge(doc, overlay), nil
+}
+
+type resolver struct {
+	stack []string // absolute paths of files being loaded
+}
+
+func (r *resolver) load(filename string) (any, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range r.stack {
+		if f == abs {
+			return nil, fmt.Errorf("%w: %s", ErrIncludeCycle, strings.Join(append(r.stack[i:], abs), " -> "))
+		}
+	}
+	r.stack = append(r.stack, abs)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %w", filename, err)
+	}
+	includes, err := includesOf(filename, doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(includes) == 0 {
+		return doc, nil
+	}
+	delete(doc.(map[string]any), IncludeKey)
+	var base any = map[string]any{}
+	for _, include := range includes {
+		v, err := r.load(include)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("config: %s: include %w", filename, err)
+			}
+			return nil, err
+		}
+		base = merge(base, v)
+	}
+	return merge(base, doc), nil
+}
+
+// parse parses jsonx data, numbers are decoded as json.Number
+func parse(data []byte) (any, error) {
+	node, err := jsonx.ReadBytes(data,
+		jsonx.WithSupportComment(),
+		jsonx.WithSupportExtraComma(),
+		jsonx.WithSupportUnquotedKey(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := jsonx.Write(&buf, node); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(&buf)
+	dec.UseNumber()
+	var doc any
+	err = dec.Decode(&doc)
+	return doc, err
+}
+
+// includesOf returns paths of files included by doc of filename
+func includesOf(filename string, doc any) ([]string, error) {
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	var paths []string
+	switch v := m[IncludeKey].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		paths = []string{v}
+	case []any:
+		for _, x := range v {
+			s, ok := x.(string)
+			if !ok {
+				return nil, fmt.Errorf("config: %s: invalid %s %v", filename, IncludeKey, m[IncludeKey])
+			}
+			paths = append(paths, s)
+		}
+	default:
+		return nil, fmt.Errorf("config: %s: invalid %s %v", filename, IncludeKey, v)
+	}
+	for i, path := range paths {
+		if !filepath.IsAbs(path) {
+			paths[i] = filepath.Join(filepath.Dir(filename), path)
+		}
+	}
+	return paths, nil
+}
+
+// merge merges src into dst, see resolve for rules
+func merge(dst, src any) any {
+	x, ok1 := dst.(map[string]any)
+	y, ok2 := src.(map[string]any)
+	if !ok1 || !ok2 {
+		return src
+	}
+	for k, v := range y {
+		if old, ok := x[k]; ok {
+			x[k] = merge(old, v)
+		} else {
+			x[k] = v
+		}
+	}
+	return x
+}
+
+// files returns paths of the config file source, files included by it and
+// overlays of all modes which exist. Unreadable or invalid files are
+// returned without their included files.
+func files(source string) []string {
+	var (
+		result  []string
+		visited = make(map[string]bool)
+		walk    func(filename string)
+	)
+	walk = func(filename string) {
+		abs, err := filepath.Abs(filename)
+		if err != nil || visited[abs] {
+			return
+		}
+		visited[abs] = true
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				result = append(result, filename)
+			}
+			return
+		}
+		result = append(result, filename)
+		doc, err := parse(data)
+		if err != nil {
+			return
+		}
+		includes, _ := includesOf(filename, doc)
+		for _, include := range includes {
+			walk(include)
+		}
+	}
+	walk(source)
+	for _, mode := range Modes {
+		walk(Overlay(source, mode))
+	}
+	return result
+}
@@ -0,0 +1,294 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Layer represents a layer of config sources, values of higher layers
+// override values of lower layers.
+type Layer int
+
+const (
+	LayerDefault Layer = iota // Configurator.Default()
+	LayerFile                 // config file
+	LayerEnv                  // environment variables
+	LayerFlag                 // command line -set flags
+)
+
+func (layer Layer) String() string {
+	switch layer {
+	case LayerDefault:
+		return "default"
+	case LayerFile:
+		return "file"
+	case LayerEnv:
+		return "env"
+	case LayerFlag:
+		return "flag"
+	default:
+		return fmt.Sprintf("Layer(%d)", int(layer))
+	}
+}
+
+// MarshalJSON implements json.Marshaler MarshalJSON method
+func (layer Layer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(layer.String())
+}
+
+// Origins records layers of values by key paths, e.g. "core.log.level".
+// Values of the file layer which equal to defaults are recorded as LayerDefault.
+type Origins map[string]Layer
+
+// Of returns the layer of value at path, the layer of the nearest recorded
+// parent returned if the path is not recorded.
+func (origins Origins) Of(path string) Layer {
+	for {
+		if layer, ok := origins[path]; ok {
+			return layer
+		}
+		i := strings.LastIndexByte(path, '.')
+		if i < 0 {
+			return LayerDefault
+		}
+		path = path[:i]
+	}
+}
+
+// OriginsOf returns origins of values of cfg loaded by Load, nil returned if
+// cfg doesn't embed BasicConfig.
+func OriginsOf(cfg Configurator) Origins {
+	if h, ok := cfg.(interface{ getOrigins() Origins }); ok {
+		return h.getOrigins()
+	}
+	return nil
+}
+
+// Overrides represents overrides of environment variables and -set flags
+// applied by Load, see Init and SetOverrides.
+type Overrides struct {
+	EnvPrefix string   // prefix of environment variables, ignored if empty
+	Sets      []string // path=value of -set flags
+}
+
+// Lookup returns the raw value at path overridden by -set flags or
+// environment variables, the last -set flag of path takes precedence over
+// the environment variable.
+func (o Overrides) Lookup(path string) (string, bool) {
+	for i := len(o.Sets) - 1; i >= 0; i-- {
+		if p, s, _ := strings.Cut(o.Sets[i], "="); p == path {
+			return s, true
+		}
+	}
+	if o.EnvPrefix == "" {
+		return "", false
+	}
+	return os.LookupEnv(EnvName(o.EnvPrefix, path))
+}
+
+// OverridesOf returns overrides recorded in cfg, zero value returned if cfg
+// doesn't embed BasicConfig.
+func OverridesOf(cfg Configurator) Overrides {
+	if h, ok := cfg.(interface{ getOverrides() Overrides }); ok {
+		return h.getOverrides()
+	}
+	return Overrides{}
+}
+
+// SetOverrides records overrides in cfg if it embeds BasicConfig, they are
+// applied by Load. Init records overrides of command line flags, and new
+// configs created for reloading should inherit overrides of the current one.
+func SetOverrides(cfg Configurator, overrides Overrides) {
+	if h, ok := cfg.(interface{ setOverrides(Overrides) }); ok {
+		h.setOverrides(overrides)
+	}
+}
+
+// setFlag implements flag.Value for repeated -set flags
+type setFlag struct {
+	sets *[]string
+}
+
+func (f setFlag) String() string {
+	if f.sets == nil {
+		return ""
+	}
+	return strings.Join(*f.sets, ",")
+}
+
+func (f setFlag) Set(s string) error {
+	if i := strings.IndexByte(s, '='); i <= 0 {
+		return fmt.Errorf("invalid %q, path=value required", s)
+	}
+	*f.sets = append(*f.sets, s)
+	return nil
+}
+
+// EnvName returns name of the environment variable for the key path,
+// e.g. EnvName("DOGE_", "core.log.level") returns "DOGE_CORE_LOG_LEVEL".
+func EnvName(prefix, path string) string {
+	return prefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(path))
+}
+
+// Load reads config from the file source of cfg, and then applies overrides
+// of environment variables and -set flags recorded in cfg, see OverridesOf.
+// Overrides are applied by Configurator.Read as a JSON patch. Origins of values
+// are recorded in cfg if it embeds BasicConfig.
+//
+// Environment variables are matched against key paths of the config, so only
+// keys exist in the default or file layer could be overridden by them, and
+// an error returned if a key path of -set flags not found. Values are decoded
+// as JSON unless the overridden value is a string, e.g.
+//
+//	DOGE_CORE_ID=3
+//	DOGE_CORE_LOG_WRITERS='["console"]'
+//	-set core.log.level=debug
+func Load(cfg Configurator, optional bool) (Origins, error) {
+	if err := Read(cfg, optional); err != nil {
+		return nil, err
+	}
+	overrides := OverridesOf(cfg)
+	envPrefix, sets := overrides.EnvPrefix, overrides.Sets
+
+	defaults, err := toJSONValue(cfg.Default())
+	if err != nil {
+		return nil, err
+	}
+	current, err := toJSONValue(cfg)
+	if err != nil {
+		return nil, err
+	}
+	origins := make(Origins)
+	leaves(current, "", func(path string, value any) {
+		if v, ok := lookup(defaults, path); ok && reflect.DeepEqual(v, value) {
+			origins[path] = LayerDefault
+		} else {
+			origins[path] = LayerFile
+		}
+	})
+
+	var (
+		patch   = make(map[string]any)
+		patched bool
+	)
+	if envPrefix != "" {
+		leaves(current, "", func(path string, value any) {
+			if s, ok := os.LookupEnv(EnvName(envPrefix, path)); ok {
+				setPath(patch, path, parseValue(s, value))
+				origins[path] = LayerEnv
+				patched = true
+			}
+		})
+	}
+	for _, set := range sets {
+		path, s, ok := strings.Cut(set, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("config: invalid override %q, path=value required", set)
+		}
+		old, ok := lookup(current, path)
+		if !ok {
+			return nil, fmt.Errorf("config: override %q: key path %s not found", set, path)
+		}
+		setPath(patch, path, parseValue(s, old))
+		for p := range origins {
+			if strings.HasPrefix(p, path+".") {
+				delete(origins, p)
+			}
+		}
+		origins[path] = LayerFlag
+		patched = true
+	}
+	if patched {
+		secrets := SecretsOf(cfg)
+		if secrets == nil {
+			secrets = make(map[string]string)
+		}
+		leaves(patch, "", func(path string, _ any) {
+			for p := range secrets {
+				if p == path || strings.HasPrefix(p, path+".") {
+					delete(secrets, p)
+				}
+			}
+		})
+		if _, err := resolveSecrets(patch, "", secrets); err != nil {
+			return nil, err
+		}
+		setSecrets(cfg, secrets)
+		data, err := json.Marshal(patch)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.Read(cfg, bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("config: apply overrides: %w", err)
+		}
+	}
+	if h, ok := cfg.(interface{ setOrigins(Origins) }); ok {
+		h.setOrigins(origins)
+	}
+	return origins, nil
+}
+
+// parseValue parses s as JSON unless the old value is a string
+func parseValue(s string, old any) any {
+	if _, ok := old.(string); ok && !strings.HasPrefix(s, `"`) {
+		return s
+	}
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+	return s
+}
+
+// leaves calls fn for each non-object value in v with its key path
+func leaves(v any, path string, fn func(path string, value any)) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		if path != "" {
+			fn(path, v)
+		}
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+		leaves(m[k], p, fn)
+	}
+}
+
+func lookup(v any, path string) (any, bool) {
+	for _, k := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[k]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func setPath(m map[string]any, path string, value any) {
+	keys := strings.Split(path, ".")
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := m[k].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			m[k] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = value
+}
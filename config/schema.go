@@ -0,0 +1,270 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gopherd/doge/encoding/jsonx"
+)
+
+// SchemaVersion is the JSON Schema dialect of schemas generated by NewSchema
+const SchemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema represents a JSON Schema of config
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+
+	// keys of properties in order of fields
+	keys []string
+}
+
+// Keys returns keys of properties in order of struct fields
+func (s *Schema) Keys() []string {
+	return s.keys
+}
+
+// NewSchema generates the JSON Schema of cfg by reflection. Fields are named by
+// their json tags, and the following tags are recognized:
+//
+//	doc:"..."            description of the field
+//	default:"..."        default value, the value of cfg.Default() used if absent
+//	enum:"a,b,c"         comma-separated allowed values
+//
+// Values of default and enum tags are decoded as JSON unless the field is a string.
+// Types which implement json.Marshaler or encoding.TextMarshaler are typed by
+// their encoded default values.
+func NewSchema(cfg Configurator) (*Schema, error) {
+	defaults, err := toJSONValue(cfg.Default())
+	if err != nil {
+		return nil, err
+	}
+	g := &schemaGenerator{visiting: make(map[reflect.Type]bool)}
+	s := g.generate(reflect.TypeOf(cfg), defaults)
+	s.Schema = SchemaVersion
+	return s, nil
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+type schemaGenerator struct {
+	visiting map[reflect.Type]bool
+}
+
+func (g *schemaGenerator) generate(t reflect.Type, value any) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	s := new(Schema)
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		s.Type = jsonType(value)
+		return s
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		s.Type = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s.Type = "integer"
+	case reflect.Float32, reflect.Float64:
+		s.Type = "number"
+	case reflect.String:
+		s.Type = "string"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s.Type = "string"
+			break
+		}
+		s.Type = "array"
+		s.Items = g.generate(t.Elem(), nil)
+	case reflect.Map:
+		s.Type = "object"
+		s.AdditionalProperties = g.generate(t.Elem(), nil)
+	case reflect.Struct:
+		s.Type = "object"
+		if g.visiting[t] {
+			break
+		}
+		g.visiting[t] = true
+		s.Properties = make(map[string]*Schema)
+		g.fields(s, t, value)
+		delete(g.visiting, t)
+	}
+	return s
+}
+
+func (g *schemaGenerator) fields(s *Schema, t reflect.Type, value any) {
+	values, _ := value.(map[string]any)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(s, ft, value)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		v := values[name]
+		prop := g.generate(f.Type, v)
+		prop.Description = f.Tag.Get("doc")
+		if def, ok := f.Tag.Lookup("default"); ok {
+			v = decodeTagValue(def, prop.Type)
+		}
+		if prop.Properties == nil {
+			prop.Default = v
+		}
+		if enum := f.Tag.Get("enum"); enum != "" {
+			for _, e := range strings.Split(enum, ",") {
+				prop.Enum = append(prop.Enum, decodeTagValue(strings.TrimSpace(e), prop.Type))
+			}
+		}
+		if _, ok := s.Properties[name]; !ok {
+			s.keys = append(s.keys, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// decodeTagValue decodes s as JSON unless typ is string
+func decodeTagValue(s, typ string) any {
+	if typ == "string" {
+		return s
+	}
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	return v
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return ""
+	}
+}
+
+// WriteSchema writes the JSON Schema of cfg to w
+func WriteSchema(w io.Writer, cfg Configurator) error {
+	s, err := NewSchema(cfg)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	return enc.Encode(s)
+}
+
+// WriteTemplate writes a jsonx template of cfg to w, values of the template are
+// defaults of the schema, and descriptions of fields are written as comments.
+func WriteTemplate(w io.Writer, cfg Configurator) error {
+	s, err := NewSchema(cfg)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := writeTemplate(&buf, s); err != nil {
+		return err
+	}
+	node, err := jsonx.Read(&buf, jsonx.WithSupportComment())
+	if err != nil {
+		return err
+	}
+	if err := jsonx.Write(w, node,
+		jsonx.WithIndent("\t"),
+		jsonx.WithSupportComment(),
+		jsonx.WithSupportUnquotedKey(),
+		jsonx.WithSupportExtraComma(),
+	); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// writeTemplate writes s as JSON with comments line by line, it's formatted by jsonx
+func writeTemplate(w *bytes.Buffer, s *Schema) error {
+	if s.Properties == nil {
+		value := s.Default
+		if value == nil {
+			switch s.Type {
+			case "array":
+				value = []any{}
+			case "object":
+				value = map[string]any{}
+			}
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		w.Write(data)
+		return nil
+	}
+	w.WriteString("{\n")
+	for i, key := range s.keys {
+		prop := s.Properties[key]
+		if prop.Description != "" {
+			for _, line := range strings.Split(prop.Description, "\n") {
+				w.WriteString("// " + line + "\n")
+			}
+		}
+		if len(prop.Enum) > 0 {
+			data, err := json.Marshal(prop.Enum)
+			if err != nil {
+				return err
+			}
+			w.WriteString("// Enum: " + string(data) + "\n")
+		}
+		w.WriteString(fmt.Sprintf("%q: ", key))
+		if err := writeTemplate(w, prop); err != nil {
+			return err
+		}
+		if i+1 < len(s.keys) {
+			w.WriteString(",")
+		}
+		w.WriteString("\n")
+	}
+	w.WriteString("}")
+	return nil
+}
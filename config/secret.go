@@ -0,0 +1,223 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gopherd/doge/crypto/cryptoutil"
+)
+
+// Secret placeholders could be used in string values of config, they are
+// resolved before decoding:
+//
+//	${env:NAME}             value of environment variable NAME
+//	${file:/run/secrets/x}  content of file, trailing newlines trimmed
+//	${enc:BASE64}           RSA-OAEP (SHA-256) encrypted value, see EncryptSecret
+//
+// Placeholders could be embedded in strings, e.g. "mysql://root:${env:DB_PASSWORD}@db",
+// and "$${" is unescaped to a literal "${". Values which contain placeholders
+// are masked by Mask, so the resolved secrets never appear in exported config
+// or content registered to discovery.
+var placeholderRegexp = regexp.MustCompile(`\$?\$\{(env|file|enc):([^}]*)\}`)
+
+// ErrSecretKeyRequired represents an error in case of resolving ${enc:...}
+// placeholders without secret key
+var ErrSecretKeyRequired = errors.New("config: secret key required")
+
+var secretKey atomic.Pointer[rsa.PrivateKey]
+
+// SetSecretKey sets the private key used to decrypt ${enc:...} placeholders
+func SetSecretKey(key *rsa.PrivateKey) {
+	secretKey.Store(key)
+}
+
+// LoadSecretKey loads the private key used to decrypt ${enc:...} placeholders
+// from a PEM file
+func LoadSecretKey(filename string) error {
+	key, err := cryptoutil.LoadRSAPrivateKeyFile(filename)
+	if err != nil {
+		return fmt.Errorf("config: load secret key: %w", err)
+	}
+	SetSecretKey(key)
+	return nil
+}
+
+// EncryptSecret encrypts plaintext by the public key, and returns it as
+// a ${enc:...} placeholder
+func EncryptSecret(pub *rsa.PublicKey, plaintext string) (string, error) {
+	data, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+	return "${enc:" + base64.StdEncoding.EncodeToString(data) + "}", nil
+}
+
+func resolveSecret(kind, arg string) (string, error) {
+	switch kind {
+	case "env":
+		value, ok := os.LookupEnv(arg)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s not found", arg)
+		}
+		return value, nil
+	case "file":
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default: // enc
+		key := secretKey.Load()
+		if key == nil {
+			return "", ErrSecretKeyRequired
+		}
+		data, err := base64.StdEncoding.DecodeString(arg)
+		if err != nil {
+			return "", err
+		}
+		data, err = rsa.DecryptOAEP(sha256.New(), nil, key, data, nil)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
+
+// resolveString resolves placeholders in s, ok is false if s has no
+// placeholders, escapes are unescaped but not reported by ok
+func resolveString(s string) (result string, ok bool, err error) {
+	if !strings.Contains(s, "${") {
+		return s, false, nil
+	}
+	result = placeholderRegexp.ReplaceAllStringFunc(s, func(m string) string {
+		if err != nil {
+			return m
+		}
+		if strings.HasPrefix(m, "$$") {
+			return m[1:]
+		}
+		ok = true
+		sub := placeholderRegexp.FindStringSubmatch(m)
+		value, e := resolveSecret(sub[1], sub[2])
+		if e != nil {
+			err = fmt.Errorf("resolve ${%s:...}: %w", sub[1], e)
+		}
+		return value
+	})
+	return result, ok, err
+}
+
+// resolveSecrets resolves placeholders of string values in doc, and records
+// original values by key paths into secrets. Indices of arrays are path
+// segments, e.g. "core.log.writers.1".
+func resolveSecrets(doc any, path string, secrets map[string]string) (any, error) {
+	join := func(k string) string {
+		if path == "" {
+			return k
+		}
+		return path + "." + k
+	}
+	switch v := doc.(type) {
+	case string:
+		s, ok, err := resolveString(v)
+		if err != nil {
+			return nil, fmt.Errorf("config: %s: %w", path, err)
+		}
+		if ok {
+			secrets[path] = v
+		}
+		return s, nil
+	case map[string]any:
+		for k, x := range v {
+			x, err := resolveSecrets(x, join(k), secrets)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = x
+		}
+	case []any:
+		for i, x := range v {
+			x, err := resolveSecrets(x, join(strconv.Itoa(i)), secrets)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = x
+		}
+	}
+	return doc, nil
+}
+
+// SecretsOf returns original values which contain placeholders by key paths
+// of cfg loaded by Read, nil returned if cfg doesn't embed BasicConfig.
+func SecretsOf(cfg Configurator) map[string]string {
+	if h, ok := cfg.(interface{ getSecrets() map[string]string }); ok {
+		return h.getSecrets()
+	}
+	return nil
+}
+
+func setSecrets(cfg Configurator, secrets map[string]string) {
+	if h, ok := cfg.(interface{ setSecrets(map[string]string) }); ok {
+		h.setSecrets(secrets)
+	}
+}
+
+// Mask returns a copy of cfg whose values resolved from placeholders are
+// replaced by the original placeholders, and literal "${" of other values
+// which look like placeholders are escaped as "$${", so that the masked
+// config could be read again.
+func Mask(cfg Configurator) (Configurator, error) {
+	secrets := SecretsOf(cfg)
+	doc, err := toJSONValue(cfg)
+	if err != nil {
+		return nil, err
+	}
+	doc = mask(doc, "", secrets)
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	masked := cfg.Default()
+	if err := json.Unmarshal(data, masked); err != nil {
+		return nil, err
+	}
+	masked.SetSource(cfg.GetSource())
+	return masked, nil
+}
+
+func mask(doc any, path string, secrets map[string]string) any {
+	join := func(k string) string {
+		if path == "" {
+			return k
+		}
+		return path + "." + k
+	}
+	if s, ok := secrets[path]; ok && path != "" {
+		return s
+	}
+	switch v := doc.(type) {
+	case string:
+		return placeholderRegexp.ReplaceAllStringFunc(v, func(m string) string {
+			return "$" + m
+		})
+	case map[string]any:
+		for k, x := range v {
+			v[k] = mask(x, join(k), secrets)
+		}
+	case []any:
+		for i, x := range v {
+			v[i] = mask(x, join(strconv.Itoa(i)), secrets)
+		}
+	}
+	return doc
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source represents a remote source of config selected by the URL scheme of
+// Configurator.GetSource, e.g.
+//
+//	file:///etc/app.conf (or simply /etc/app.conf)
+//	discovery://config.gate/1?driver=redis&source=redis%3A%2F%2F127.0.0.1%3A6379
+//	https://config.example.com/gate.conf
+//
+// Sources without scheme are file paths. Includes and overlays are supported
+// by file sources only. Other sources should be registered by RegisterSource,
+// e.g. sources of discovery and http(s) schemes are registered by importing
+//
+//	import _ "github.com/gopherd/doge/config/source/discoverysource"
+//	import _ "github.com/gopherd/doge/config/source/httpsource"
+type Source interface {
+	// Read reads content of config from url u, errors which wrap
+	// fs.ErrNotExist should be returned if the config not found.
+	Read(ctx context.Context, u *url.URL) ([]byte, error)
+}
+
+// SourceWatcher is an optional interface which could be implemented by Source
+// to push changes of config.
+type SourceWatcher interface {
+	// Watch watches changes of config at url u, a value is sent to the
+	// returned channel once the config may be changed. The channel will be
+	// closed after ctx done.
+	Watch(ctx context.Context, u *url.URL) (<-chan struct{}, error)
+}
+
+// ReadTimeout is the timeout of reading config from remote sources
+var ReadTimeout = 10 * time.Second
+
+var (
+	sourcesMu sync.RWMutex
+	sources   = make(map[string]Source)
+)
+
+// RegisterSource makes a config source available by the provided URL scheme
+func RegisterSource(scheme string, source Source) {
+	if source == nil {
+		panic("config: RegisterSource source is nil")
+	}
+	if scheme == "file" {
+		panic("config: RegisterSource called for reserved scheme file")
+	}
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if _, dup := sources[scheme]; dup {
+		panic("config: RegisterSource called twice for scheme " + scheme)
+	}
+	sources[scheme] = source
+}
+
+// remoteSource returns the registered Source of source, nil returned with
+// the file path if source is a file.
+func remoteSource(source string) (Source, *url.URL, string, error) {
+	scheme, _, ok := strings.Cut(source, "://")
+	if !ok || scheme == "file" {
+		return nil, nil, strings.TrimPrefix(source, "file://"), nil
+	}
+	sourcesMu.RLock()
+	src, ok := sources[scheme]
+	sourcesMu.RUnlock()
+	if !ok {
+		return nil, nil, "", fmt.Errorf("config: unknown source scheme %q (forgotten import?)", scheme)
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return src, u, "", nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx which carries cfg, remote sources could
+// get the config being read by FromContext.
+func NewContext(ctx context.Context, cfg Configurator) context.Context {
+	return context.WithValue(ctx, contextKey{}, cfg)
+}
+
+// FromContext returns the config carried by ctx, nil returned if not found
+func FromContext(ctx context.Context) Configurator {
+	cfg, _ := ctx.Value(contextKey{}).(Configurator)
+	return cfg
+}
+
+func readRemote(ctx context.Context, src Source, u *url.URL) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeout)
+	defer cancel()
+	return src.Read(ctx, u)
+}
+
+// watchRemote watches the remote source by SourceWatcher if supported,
+// otherwise a value is sent to ch every interval.
+func watchRemote(ctx context.Context, src Source, u *url.URL, interval time.Duration, ch chan<- struct{}) {
+	defer close(ch)
+	if w, ok := src.(SourceWatcher); ok {
+		changes, err := w.Watch(ctx, u)
+		if err == nil {
+			for range changes {
+				trigger(ch)
+			}
+			return
+		}
+	}
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			trigger(ch)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package discoverysource implements a config source registered as "discovery",
+// which reads config from the content of a service in discovery:
+//
+//	discovery://name/key?driver=...&source=...
+//
+// The driver and source could be omitted, e.g. discovery://config.gate/1, then
+// core.discovery.name and core.discovery.source are used, which are read from
+// overrides of -set flags, environment variables or the config being read (its
+// defaults) in order, since the config itself is not read yet. The config is
+// carried by the context, see config.NewContext.
+//
+//	import _ "github.com/gopherd/doge/config/source/discoverysource"
+package discoverysource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gopherd/doge/config"
+	"github.com/gopherd/doge/service/discovery"
+)
+
+func init() {
+	config.RegisterSource("discovery", &source{opened: make(map[string]discovery.Discovery)})
+}
+
+// source implements config.Source and config.SourceWatcher
+type source struct {
+	mu     sync.Mutex
+	opened map[string]discovery.Discovery // driver+source => discovery
+}
+
+func (s *source) open(ctx context.Context, u *url.URL) (d discovery.Discovery, name, key string, err error) {
+	name, key = u.Host, strings.TrimPrefix(u.Path, "/")
+	if name == "" || key == "" {
+		return nil, "", "", fmt.Errorf("config: invalid discovery source %q, discovery://name/key required", u.Redacted())
+	}
+	query := u.Query()
+	driver, src := query.Get("driver"), query.Get("source")
+	if driver == "" {
+		driver, src = defaultDiscovery(config.FromContext(ctx))
+	}
+	if driver == "" {
+		return nil, "", "", fmt.Errorf("config: driver of discovery source %q required, set ?driver= or core.discovery.name", u.Redacted())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := driver + "\x00" + src
+	if d = s.opened[k]; d == nil {
+		if d, err = discovery.Open(driver, src); err != nil {
+			return nil, "", "", err
+		}
+		s.opened[k] = d
+	}
+	return d, name, key, nil
+}
+
+// defaultDiscovery returns driver and source of discovery by core.discovery
+// of cfg overridden by -set flags or environment variables.
+func defaultDiscovery(cfg config.Configurator) (driver, source string) {
+	if cfg == nil {
+		return "", ""
+	}
+	d, overrides := cfg.GetCore().Discovery, config.OverridesOf(cfg)
+	for path, v := range map[string]*string{
+		"core.discovery.name":   &d.Name,
+		"core.discovery.source": &d.Source,
+	} {
+		if s, ok := overrides.Lookup(path); ok {
+			*v = s
+			if strings.HasPrefix(s, `"`) {
+				json.Unmarshal([]byte(s), v)
+			}
+		}
+	}
+	return d.Name, d.Source
+}
+
+// Read implements config.Source Read method
+func (s *source) Read(ctx context.Context, u *url.URL) ([]byte, error) {
+	d, name, key, err := s.open(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	content, err := d.Find(ctx, name, key)
+	if err != nil {
+		if discovery.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %w", fs.ErrNotExist, err)
+		}
+		return nil, err
+	}
+	return []byte(content), nil
+}
+
+// Watch implements config.SourceWatcher Watch method
+func (s *source) Watch(ctx context.Context, u *url.URL) (<-chan struct{}, error) {
+	d, name, key, err := s.open(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	events, err := discovery.Watch(ctx, d, name)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+		for e := range events {
+			if e.ID != key {
+				continue
+			}
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,44 @@
+// Package httpsource implements a config source registered as "http" and
+// "https", which reads config from the url by GET.
+//
+//	import _ "github.com/gopherd/doge/config/source/httpsource"
+package httpsource
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+
+	"github.com/gopherd/doge/config"
+)
+
+func init() {
+	config.RegisterSource("http", source{})
+	config.RegisterSource("https", source{})
+}
+
+// source implements config.Source
+type source struct{}
+
+// Read implements config.Source Read method
+func (source) Read(ctx context.Context, u *url.URL) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("config: %s: %w", u.Redacted(), fs.ErrNotExist)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil, fmt.Errorf("config: %s: unexpected status %s", u.Redacted(), resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
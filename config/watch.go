@@ -0,0 +1,167 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Hash returns hex encoded sha256 hash of content of the config source, files
+// included by a file source and its overlays are hashed too. Empty string
+// returned if the source not found. ctx is passed to remote sources, see
+// NewContext.
+func Hash(ctx context.Context, source string) (string, error) {
+	src, u, source, err := remoteSource(source)
+	if err != nil {
+		return "", err
+	}
+	if src != nil {
+		data, err := readRemote(ctx, src, u)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return "", nil
+			}
+			return "", err
+		}
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	if _, err := os.Stat(source); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	h := sha256.New()
+	for _, filename := range files(source) {
+		data, err := os.ReadFile(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		io.WriteString(h, filename)
+		h.Write([]byte{0})
+		h.Write(data)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Watch watches the config source, a value is sent to the returned channel
+// once the config may be changed, notifications are coalesced if the receiver
+// is busy. So receivers should compare Hash of the source to filter out false
+// notifications. The channel is closed after ctx done, or immediately if the
+// source is invalid.
+//
+// Changes of remote sources are pushed if the Source implements SourceWatcher,
+// otherwise notifications are sent every interval. ctx is passed to remote
+// sources, see NewContext.
+//
+// Files included by a file source and its overlays are watched too, included
+// files are resolved once while Watch called. Directories of the files are
+// watched by inotify on linux, so that files could be replaced by renaming,
+// and only events of the files and symbolic links they resolve through are
+// notified. Modification time and size of the files are polled every interval
+// on other platforms or if inotify unavailable.
+func Watch(ctx context.Context, source string, interval time.Duration) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	src, u, source, err := remoteSource(source)
+	if err != nil {
+		close(ch)
+		return ch
+	}
+	if src != nil {
+		go watchRemote(ctx, src, u, interval, ch)
+		return ch
+	}
+	// the source and overlays are watched even if they don't exist yet
+	filenames := append([]string{source}, files(source)...)
+	for _, mode := range Modes {
+		filenames = append(filenames, Overlay(source, mode))
+	}
+	filenames = append(filenames, links(filenames)...)
+	slices.Sort(filenames)
+	filenames = slices.Compact(filenames)
+	if !notify(ctx, filenames, ch) {
+		go poll(ctx, filenames, interval, ch)
+	}
+	return ch
+}
+
+// links returns symbolic links which filenames resolve through, e.g. the
+// "..data" link of Kubernetes ConfigMap volumes, which is replaced to update
+// "app.conf -> ..data/app.conf".
+func links(filenames []string) []string {
+	var result []string
+	for _, filename := range filenames {
+		path := filepath.Clean(filename)
+		for i := 0; i < 8; i++ {
+			target, err := os.Readlink(path)
+			if err != nil {
+				break
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(path), target)
+			}
+			for dir := filepath.Dir(target); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+				if fi, err := os.Lstat(dir); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+					result = append(result, dir)
+				}
+			}
+			result = append(result, target)
+			path = target
+		}
+	}
+	return result
+}
+
+func trigger(ch chan<- struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func poll(ctx context.Context, filenames []string, interval time.Duration, ch chan<- struct{}) {
+	defer close(ch)
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	type stat struct {
+		modTime time.Time
+		size    int64
+	}
+	stats := func() []stat {
+		result := make([]stat, len(filenames))
+		for i, filename := range filenames {
+			if fi, err := os.Stat(filename); err != nil {
+				result[i].size = -1
+			} else {
+				result[i] = stat{fi.ModTime(), fi.Size()}
+			}
+		}
+		return result
+	}
+	last := stats()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if curr := stats(); !slices.EqualFunc(last, curr, func(x, y stat) bool {
+				return x.modTime.Equal(y.modTime) && x.size == y.size
+			}) {
+				last = curr
+				trigger(ch)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
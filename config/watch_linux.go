@@ -0,0 +1,90 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// notify watches directories of filenames by inotify, only events of the
+// files are notified. false returned if inotify unavailable.
+func notify(ctx context.Context, filenames []string, ch chan<- struct{}) bool {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return false
+	}
+	const mask = syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_ATTRIB |
+		syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+	var (
+		dirs    = make(map[string]int32)          // dir => watch descriptor
+		watched = make(map[int32]map[string]bool) // watch descriptor => names
+	)
+	for _, filename := range filenames {
+		dir, name := filepath.Split(filepath.Clean(filename))
+		if dir == "" {
+			dir = "."
+		}
+		wd, ok := dirs[dir]
+		if !ok {
+			w, err := syscall.InotifyAddWatch(fd, dir, mask)
+			if err != nil {
+				syscall.Close(fd)
+				return false
+			}
+			wd = int32(w)
+			dirs[dir] = wd
+			if watched[wd] == nil {
+				watched[wd] = make(map[string]bool)
+			}
+		}
+		watched[wd][name] = true
+	}
+	// the nonblocking fd is added to the runtime poller, so that Read
+	// returns once the file closed
+	f := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+	go func() {
+		defer close(ch)
+		var buf [4096]byte
+		for {
+			n, err := f.Read(buf[:])
+			if err != nil {
+				return
+			}
+			if matchEvents(buf[:n], watched) {
+				trigger(ch)
+			}
+		}
+	}()
+	return true
+}
+
+// matchEvents reports whether any inotify event in buf is an event of the
+// watched names, overflows and removed watches are always matched.
+func matchEvents(buf []byte, watched map[int32]map[string]bool) bool {
+	for len(buf) >= syscall.SizeofInotifyEvent {
+		e := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[0]))
+		end := syscall.SizeofInotifyEvent + int(e.Len)
+		if end > len(buf) {
+			return true
+		}
+		if e.Mask&(syscall.IN_Q_OVERFLOW|syscall.IN_IGNORED) != 0 {
+			return true
+		}
+		name := buf[syscall.SizeofInotifyEvent:end]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		if watched[e.Wd][string(name)] {
+			return true
+		}
+		buf = buf[end:]
+	}
+	return false
+}
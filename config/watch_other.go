@@ -0,0 +1,10 @@
+//go:build !linux
+
+package config
+
+import "context"
+
+// notify is not supported on this platform
+func notify(ctx context.Context, filenames []string, ch chan<- struct{}) bool {
+	return false
+}
@@ -11,8 +11,9 @@ import (
 )
 
 var (
-	ErrNonRSAPublicKey          = errors.New("non-rsa public key")
-	ErrInvalidPublicKeyPemBlock = errors.New("invalid public key pem block")
+	ErrNonRSAPublicKey           = errors.New("non-rsa public key")
+	ErrInvalidPublicKeyPemBlock  = errors.New("invalid public key pem block")
+	ErrInvalidPrivateKeyPemBlock = errors.New("invalid private key pem block")
 )
 
 func GenerateRSAPemFile(priKey *rsa.PrivateKey, priFilename, pubFilename string) error {
@@ -62,6 +63,9 @@ func LoadRSAPrivateKeyFile(priFilename string) (*rsa.PrivateKey, error) {
 		return nil, err
 	}
 	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPrivateKeyPemBlock
+	}
 	return x509.ParsePKCS1PrivateKey(block.Bytes)
 }
 
@@ -0,0 +1,65 @@
+package metrics
+
+import "bufio"
+
+// Counter is a metric which only increases
+type Counter struct {
+	desc  *desc // nil if it's a child of CounterVec
+	value value
+}
+
+// NewCounter creates a Counter without labels
+func NewCounter(name, help string) *Counter {
+	return &Counter{desc: newDesc(name, help, "counter", nil)}
+}
+
+// Inc increments the counter by 1
+func (c *Counter) Inc() {
+	c.value.add(1)
+}
+
+// Add adds delta to the counter, it panics if delta < 0
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.value.add(delta)
+}
+
+// Value returns current value of the counter
+func (c *Counter) Value() float64 {
+	return c.value.load()
+}
+
+func (c *Counter) describe() *desc { return c.desc }
+
+func (c *Counter) collect(w *bufio.Writer) {
+	writeSample(w, c.desc.name, nil, nil, "", "", c.Value())
+}
+
+// CounterVec is a group of counters partitioned by label values
+type CounterVec struct {
+	vec *vec[Counter]
+}
+
+// NewCounterVec creates a CounterVec
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{
+		vec: newVec(newDesc(name, help, "counter", labelNames), func() *Counter { return new(Counter) }),
+	}
+}
+
+// With returns the counter by label values, it panics if the number of
+// label values mismatched.
+func (v *CounterVec) With(labelValues ...string) *Counter {
+	return v.vec.with(labelValues)
+}
+
+func (v *CounterVec) describe() *desc { return v.vec.desc }
+
+func (v *CounterVec) collect(w *bufio.Writer) {
+	d := v.vec.desc
+	v.vec.each(func(labelValues []string, c *Counter) {
+		writeSample(w, d.name, d.labelNames, labelValues, "", "", c.Value())
+	})
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import "bufio"
+
+// Gauge is a metric which could increase or decrease
+type Gauge struct {
+	desc  *desc // nil if it's a child of GaugeVec
+	value value
+}
+
+// NewGauge creates a Gauge without labels
+func NewGauge(name, help string) *Gauge {
+	return &Gauge{desc: newDesc(name, help, "gauge", nil)}
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64) {
+	g.value.store(v)
+}
+
+// Add adds delta to the gauge
+func (g *Gauge) Add(delta float64) {
+	g.value.add(delta)
+}
+
+// Inc increments the gauge by 1
+func (g *Gauge) Inc() {
+	g.value.add(1)
+}
+
+// Dec decrements the gauge by 1
+func (g *Gauge) Dec() {
+	g.value.add(-1)
+}
+
+// Value returns current value of the gauge
+func (g *Gauge) Value() float64 {
+	return g.value.load()
+}
+
+func (g *Gauge) describe() *desc { return g.desc }
+
+func (g *Gauge) collect(w *bufio.Writer) {
+	writeSample(w, g.desc.name, nil, nil, "", "", g.Value())
+}
+
+// GaugeVec is a group of gauges partitioned by label values
+type GaugeVec struct {
+	vec *vec[Gauge]
+}
+
+// NewGaugeVec creates a GaugeVec
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{
+		vec: newVec(newDesc(name, help, "gauge", labelNames), func() *Gauge { return new(Gauge) }),
+	}
+}
+
+// With returns the gauge by label values, it panics if the number of
+// label values mismatched.
+func (v *GaugeVec) With(labelValues ...string) *Gauge {
+	return v.vec.with(labelValues)
+}
+
+func (v *GaugeVec) describe() *desc { return v.vec.desc }
+
+func (v *GaugeVec) collect(w *bufio.Writer) {
+	d := v.vec.desc
+	v.vec.each(func(labelValues []string, g *Gauge) {
+		writeSample(w, d.name, d.labelNames, labelValues, "", "", g.Value())
+	})
+}
+
+// GaugeFunc is a gauge whose value is read from a function while collecting
+type GaugeFunc struct {
+	desc *desc
+	fn   func() float64
+}
+
+// NewGaugeFunc creates a GaugeFunc, fn MUST be concurrent-safe
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return &GaugeFunc{
+		desc: newDesc(name, help, "gauge", nil),
+		fn:   fn,
+	}
+}
+
+func (g *GaugeFunc) describe() *desc { return g.desc }
+
+func (g *GaugeFunc) collect(w *bufio.Writer) {
+	writeSample(w, g.desc.name, nil, nil, "", "", g.fn())
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+// DefaultBuckets are the default upper bounds of histogram buckets in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ExponentialBuckets returns count buckets, the first upper bound is start,
+// and each following upper bound is factor times of the previous one.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// Histogram samples observations and counts them in buckets
+type Histogram struct {
+	desc    *desc // nil if it's a child of HistogramVec
+	buckets []float64
+	counts  []uint64 // counts[i] is the number of observations in (buckets[i-1], buckets[i]]
+	count   uint64
+	sum     value
+}
+
+func newHistogram(d *desc, buckets []float64) *Histogram {
+	return &Histogram{
+		desc:    d,
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func fixBuckets(buckets []float64) []float64 {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	if n := len(buckets); math.IsInf(buckets[n-1], 1) {
+		buckets = buckets[:n-1]
+	}
+	return buckets
+}
+
+// NewHistogram creates a Histogram without labels, DefaultBuckets used if
+// buckets is empty.
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return newHistogram(newDesc(name, help, "histogram", nil), fixBuckets(buckets))
+}
+
+// Observe adds an observation v
+func (h *Histogram) Observe(v float64) {
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		atomic.AddUint64(&h.counts[i], 1)
+	}
+	h.sum.add(v)
+	atomic.AddUint64(&h.count, 1)
+}
+
+// Count returns the number of observations
+func (h *Histogram) Count() uint64 {
+	return atomic.LoadUint64(&h.count)
+}
+
+// Sum returns sum of observations
+func (h *Histogram) Sum() float64 {
+	return h.sum.load()
+}
+
+func (h *Histogram) describe() *desc { return h.desc }
+
+func (h *Histogram) collect(w *bufio.Writer) {
+	h.write(w, h.desc, nil)
+}
+
+func (h *Histogram) write(w *bufio.Writer, d *desc, labelValues []string) {
+	var (
+		count      = h.Count()
+		sum        = h.Sum()
+		cumulative uint64
+	)
+	for i, upper := range h.buckets {
+		cumulative += atomic.LoadUint64(&h.counts[i])
+		writeSample(w, d.name+"_bucket", d.labelNames, labelValues, "le", formatFloat(upper), float64(cumulative))
+	}
+	if cumulative > count {
+		// observations added while collecting
+		count = cumulative
+	}
+	writeSample(w, d.name+"_bucket", d.labelNames, labelValues, "le", "+Inf", float64(count))
+	writeSample(w, d.name+"_sum", d.labelNames, labelValues, "", "", sum)
+	writeSample(w, d.name+"_count", d.labelNames, labelValues, "", "", float64(count))
+}
+
+// HistogramVec is a group of histograms partitioned by label values
+type HistogramVec struct {
+	vec *vec[Histogram]
+}
+
+// NewHistogramVec creates a HistogramVec, DefaultBuckets used if buckets is empty.
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	buckets = fixBuckets(buckets)
+	return &HistogramVec{
+		vec: newVec(newDesc(name, help, "histogram", labelNames), func() *Histogram {
+			return newHistogram(nil, buckets)
+		}),
+	}
+}
+
+// With returns the histogram by label values, it panics if the number of
+// label values mismatched.
+func (v *HistogramVec) With(labelValues ...string) *Histogram {
+	return v.vec.with(labelValues)
+}
+
+func (v *HistogramVec) describe() *desc { return v.vec.desc }
+
+func (v *HistogramVec) collect(w *bufio.Writer) {
+	d := v.vec.desc
+	v.vec.each(func(labelValues []string, h *Histogram) {
+		h.write(w, d, labelValues)
+	})
+}
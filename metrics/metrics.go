@@ -0,0 +1,314 @@
+// Package metrics implements counters, gauges and histograms with labels,
+// and exposes them in Prometheus text format.
+//
+//	var requests = metrics.MustRegister(metrics.NewCounterVec(
+//		"http_requests_total", "Number of http requests", "path",
+//	))
+//
+//	requests.With("/login").Inc()
+//	httpd.Handle("/metrics", metrics.Handler())
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrDuplicated  = errors.New("metrics: duplicated metric")
+	ErrInvalidName = errors.New("metrics: invalid metric name")
+)
+
+// Metric represents a collectable metric, it's implemented by types of
+// this package only.
+type Metric interface {
+	describe() *desc
+	collect(w *bufio.Writer)
+}
+
+// desc describes a metric
+type desc struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+}
+
+func newDesc(name, help, typ string, labelNames []string) *desc {
+	return &desc{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: labelNames,
+	}
+}
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	w.WriteString("# HELP ")
+	w.WriteString(d.name)
+	w.WriteByte(' ')
+	w.WriteString(helpEscaper.Replace(d.help))
+	w.WriteString("\n# TYPE ")
+	w.WriteString(d.name)
+	w.WriteByte(' ')
+	w.WriteString(d.typ)
+	w.WriteByte('\n')
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// writeSample writes a sample line: name{labels,extra="value"} value
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, extraName, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(labelNames) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i := range labelNames {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, labelNames[i], labelValues[i])
+		}
+		if extraName != "" {
+			if len(labelNames) > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func writeLabel(w *bufio.Writer, name, value string) {
+	w.WriteString(name)
+	w.WriteString(`="`)
+	w.WriteString(labelEscaper.Replace(value))
+	w.WriteByte('"')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// value is a float64 updated atomically
+type value struct {
+	bits uint64
+}
+
+func (v *value) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&v.bits))
+}
+
+func (v *value) store(x float64) {
+	atomic.StoreUint64(&v.bits, math.Float64bits(x))
+}
+
+func (v *value) add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		new := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&v.bits, old, new) {
+			return
+		}
+	}
+}
+
+// vec holds children of a metric by label values
+type vec[T any] struct {
+	desc     *desc
+	newChild func() *T
+
+	mu       sync.RWMutex
+	children map[string]*child[T]
+}
+
+type child[T any] struct {
+	labelValues []string
+	metric      *T
+}
+
+func newVec[T any](d *desc, newChild func() *T) *vec[T] {
+	return &vec[T]{
+		desc:     d,
+		newChild: newChild,
+		children: make(map[string]*child[T]),
+	}
+}
+
+// with returns the child by label values, it panics if the number of label
+// values mismatched.
+func (v *vec[T]) with(labelValues []string) *T {
+	if len(labelValues) != len(v.desc.labelNames) {
+		panic("metrics: " + v.desc.name + ": want " + strconv.Itoa(len(v.desc.labelNames)) +
+			" label values, got " + strconv.Itoa(len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mu.RLock()
+	c, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return c.metric
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c, ok = v.children[key]; !ok {
+		c = &child[T]{
+			labelValues: append([]string(nil), labelValues...),
+			metric:      v.newChild(),
+		}
+		v.children[key] = c
+	}
+	return c.metric
+}
+
+// each calls fn for each child sorted by label values
+func (v *vec[T]) each(fn func(labelValues []string, metric *T)) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	children := make([]*child[T], 0, len(keys))
+	sort.Strings(keys)
+	for _, key := range keys {
+		children = append(children, v.children[key])
+	}
+	v.mu.RUnlock()
+	for _, c := range children {
+		fn(c.labelValues, c.metric)
+	}
+}
+
+// Registry holds registered metrics
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]Metric
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]Metric),
+	}
+}
+
+// Default is the default registry used by package level functions
+var Default = NewRegistry()
+
+// Register registers m to the registry
+func (r *Registry) Register(m Metric) error {
+	d := m.describe()
+	if d == nil || !validName(d.name) {
+		return ErrInvalidName
+	}
+	for _, name := range d.labelNames {
+		if !validName(name) || strings.HasPrefix(name, "__") {
+			return ErrInvalidName
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[d.name]; ok {
+		return ErrDuplicated
+	}
+	r.metrics[d.name] = m
+	return nil
+}
+
+// Unregister unregisters the metric by name
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[name]; !ok {
+		return false
+	}
+	delete(r.metrics, name)
+	return true
+}
+
+// Write writes all metrics sorted by name in Prometheus text format
+func (r *Registry) Write(writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+	r.mu.RLock()
+	metrics := make([]Metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		metrics = append(metrics, m)
+	}
+	r.mu.RUnlock()
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].describe().name < metrics[j].describe().name
+	})
+	for _, m := range metrics {
+		m.describe().writeHeader(w)
+		m.collect(w)
+	}
+	return w.Flush()
+}
+
+// ServeHTTP implements http.Handler ServeHTTP method
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Write(w)
+}
+
+// Register registers m to the default registry
+func Register(m Metric) error {
+	return Default.Register(m)
+}
+
+// MustRegister registers m to the default registry and returns m,
+// it panics if failed.
+func MustRegister[M Metric](m M) M {
+	if err := Default.Register(m); err != nil {
+		panic(err.Error() + ": " + describeName(m))
+	}
+	return m
+}
+
+func describeName(m Metric) string {
+	if d := m.describe(); d != nil {
+		return d.name
+	}
+	return ""
+}
+
+// Handler returns the http handler which serves metrics of the default registry
+func Handler() http.Handler {
+	return Default
+}
@@ -0,0 +1,87 @@
+package metrics_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gopherd/doge/metrics"
+)
+
+func TestRegistry(t *testing.T) {
+	r := metrics.NewRegistry()
+	counter := metrics.NewCounterVec("requests_total", "Number of requests", "path", "code")
+	gauge := metrics.NewGauge("connections", "Number of connections\nin use")
+	histogram := metrics.NewHistogram("latency_seconds", "Latency", []float64{0.1, 1})
+	for _, m := range []metrics.Metric{counter, gauge, histogram, metrics.NewGaugeFunc("answer", "", func() float64 { return 42 })} {
+		if err := r.Register(m); err != nil {
+			t.Fatalf("register error: %v", err)
+		}
+	}
+	if err := r.Register(metrics.NewCounter("connections", "")); !errors.Is(err, metrics.ErrDuplicated) {
+		t.Fatalf("want ErrDuplicated, got %v", err)
+	}
+	if err := r.Register(metrics.NewCounter("0bad", "")); !errors.Is(err, metrics.ErrInvalidName) {
+		t.Fatalf("want ErrInvalidName, got %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				counter.With("/login", "200").Inc()
+			}
+		}()
+	}
+	wg.Wait()
+	counter.With(`/a"b`, "500").Add(2)
+	gauge.Set(3)
+	gauge.Dec()
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	want := `# HELP answer 
+# TYPE answer gauge
+answer 42
+# HELP connections Number of connections\nin use
+# TYPE connections gauge
+connections 2
+# HELP latency_seconds Latency
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 5.55
+latency_seconds_count 3
+# HELP requests_total Number of requests
+# TYPE requests_total counter
+requests_total{path="/a\"b",code="500"} 2
+requests_total{path="/login",code="200"} 1000
+`
+	if got := buf.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	h := metrics.MustRegister(metrics.NewHistogramVec("test_handler_seconds", "", nil, "module"))
+	defer metrics.Default.Unregister("test_handler_seconds")
+	h.With("foo").Observe(0.2)
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `test_handler_seconds_bucket{module="foo",le="0.25"} 1`) {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,56 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+
+	"github.com/gopherd/doge/tracing"
+)
+
+// envelopeMagic prefixes contents wrapped by WrapEnvelope
+var envelopeMagic = []byte{0, 0xff, 'E', 'V'}
+
+// WrapEnvelope wraps content with the span context in ctx:
+//
+//	|magic(4 bytes)|traceparent.size(uvarint)|traceparent|content|
+//
+// content returned as is if ctx carries no span context.
+func WrapEnvelope(ctx context.Context, content []byte) []byte {
+	sc := tracing.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return content
+	}
+	traceparent := sc.Traceparent()
+	buf := make([]byte, 0, len(envelopeMagic)+binary.MaxVarintLen64+len(traceparent)+len(content))
+	buf = append(buf, envelopeMagic...)
+	buf = binary.AppendUvarint(buf, uint64(len(traceparent)))
+	buf = append(buf, traceparent...)
+	return append(buf, content...)
+}
+
+// UnwrapEnvelope returns a copy of ctx carrying the remote span context and
+// the content wrapped by WrapEnvelope. ctx and data returned as is if data is
+// not an envelope.
+func UnwrapEnvelope(ctx context.Context, data []byte) (context.Context, []byte) {
+	if !bytes.HasPrefix(data, envelopeMagic) {
+		return ctx, data
+	}
+	off := len(envelopeMagic)
+	size, n := binary.Uvarint(data[off:])
+	if n <= 0 || size > uint64(len(data)-off-n) {
+		return ctx, data
+	}
+	off += n
+	sc, err := tracing.ParseTraceparent(string(data[off : off+int(size)]))
+	if err != nil {
+		return ctx, data
+	}
+	return tracing.ContextWithRemote(ctx, sc), data[off+int(size):]
+}
+
+// PublishContext publishes content wrapped with the span context in ctx to
+// topic, consumers should unwrap it by UnwrapEnvelope.
+func PublishContext(ctx context.Context, conn Conn, topic string, content []byte) error {
+	return conn.Publish(topic, WrapEnvelope(ctx, content))
+}
@@ -0,0 +1,31 @@
+package mq_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/gopherd/doge/mq"
+	"github.com/gopherd/doge/tracing"
+)
+
+func TestEnvelope(t *testing.T) {
+	content := []byte("hello")
+	if got := mq.WrapEnvelope(context.Background(), content); !bytes.Equal(got, content) {
+		t.Fatalf("content should not be wrapped without trace context")
+	}
+	ctx, got := mq.UnwrapEnvelope(context.Background(), content)
+	if !bytes.Equal(got, content) || tracing.SpanContextFromContext(ctx).IsValid() {
+		t.Fatalf("unexpected unwrapped content: %q", got)
+	}
+
+	ctx, span := tracing.Start(context.Background(), "publish")
+	data := mq.WrapEnvelope(ctx, content)
+	ctx, got = mq.UnwrapEnvelope(context.Background(), data)
+	if !bytes.Equal(got, content) {
+		t.Fatalf("want %q, got %q", content, got)
+	}
+	if sc := tracing.SpanContextFromContext(ctx); sc != span.Context() {
+		t.Fatalf("want %v, got %v", span.Context(), sc)
+	}
+}
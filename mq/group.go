@@ -0,0 +1,197 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gopherd/doge/internal/uuid"
+	"github.com/gopherd/doge/service/discovery"
+)
+
+// ErrGroupUnsupported represents an error in case of the conn doesn't support consumer groups
+var ErrGroupUnsupported = errors.New("mq: consumer group unsupported")
+
+// Partitions is the number of partitions of each topic
+const Partitions = 64
+
+// GroupSubscriber is an optional interface which could be implemented by Conn
+// to support consumer groups.
+type GroupSubscriber interface {
+	// SubscribeGroup subscribes topic with consumer as a member of group.
+	// Each message is delivered to one member of each group.
+	SubscribeGroup(topic, group string, consumer Consumer) error
+}
+
+// KeyPublisher is an optional interface which could be implemented by Conn
+// to support partitioned topics.
+type KeyPublisher interface {
+	// PublishKey publishes message content with a partition key to topic.
+	// Messages with the same key are delivered in order to the same member
+	// of each group.
+	PublishKey(topic, key string, content []byte) error
+}
+
+// SubscribeGroup subscribes topic with consumer as a member of group,
+// ErrGroupUnsupported returned if conn doesn't implement GroupSubscriber.
+func SubscribeGroup(conn Conn, topic, group string, consumer Consumer) error {
+	if gs, ok := conn.(GroupSubscriber); ok {
+		return gs.SubscribeGroup(topic, group, consumer)
+	}
+	return ErrGroupUnsupported
+}
+
+// PublishKey publishes message content with a partition key to topic,
+// the key is ignored if conn doesn't implement KeyPublisher.
+func PublishKey(conn Conn, topic, key string, content []byte) error {
+	if kp, ok := conn.(KeyPublisher); ok {
+		return kp.PublishKey(topic, key, content)
+	}
+	return conn.Publish(topic, content)
+}
+
+// Partition returns the partition of key in range [0, Partitions)
+func Partition(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % Partitions)
+}
+
+// Membership represents a member of consumer group registered in discovery.
+// Members of the group are watched from discovery, and partitions are assigned
+// to members by rendezvous hashing, so only partitions of joined or left
+// members move while rebalancing.
+//
+// Membership views of members converge asynchronously, a message may be
+// accepted by none or more than one member while rebalancing.
+type Membership struct {
+	discovery discovery.Discovery
+	name      string
+	id        string
+	ttl       time.Duration
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	mu      sync.RWMutex
+	members []string // sorted member ids
+	owners  [Partitions]string
+}
+
+// JoinGroup registers a new member of group for topic in discovery, members
+// are registered with ttl and kept alive until Leave called.
+func JoinGroup(d discovery.Discovery, topic, group string, ttl time.Duration) (*Membership, error) {
+	if ttl <= 0 {
+		ttl = 10 * time.Second
+	}
+	m := &Membership{
+		discovery: d,
+		name:      "mq/group/" + topic + "/" + group,
+		id:        uuid.NewString(),
+		ttl:       ttl,
+		done:      make(chan struct{}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	if err := d.Register(ctx, m.name, m.id, "", true, ttl); err != nil {
+		cancel()
+		return nil, err
+	}
+	events, err := discovery.Watch(ctx, d, m.name)
+	if err != nil {
+		cancel()
+		d.Unregister(context.Background(), m.name, m.id)
+		return nil, err
+	}
+	m.update(func(members map[string]bool) { members[m.id] = true })
+	go m.run(ctx, events)
+	return m, nil
+}
+
+// ID returns the member id
+func (m *Membership) ID() string {
+	return m.id
+}
+
+// Members returns sorted ids of all known members
+func (m *Membership) Members() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.members...)
+}
+
+// Owns reports whether the partition of key is assigned to the member
+func (m *Membership) Owns(key string) bool {
+	p := Partition(key)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.owners[p] == m.id
+}
+
+// Leave unregisters the member from discovery
+func (m *Membership) Leave() error {
+	m.cancel()
+	<-m.done
+	return m.discovery.Unregister(context.Background(), m.name, m.id)
+}
+
+func (m *Membership) run(ctx context.Context, events <-chan discovery.Event) {
+	defer close(m.done)
+	ticker := time.NewTicker(m.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			m.update(func(members map[string]bool) {
+				switch e.Type {
+				case discovery.Put:
+					members[e.ID] = true
+				case discovery.Delete:
+					delete(members, e.ID)
+				}
+				members[m.id] = true
+			})
+		case <-ticker.C:
+			m.discovery.Register(ctx, m.name, m.id, "", false, m.ttl)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Membership) update(fn func(members map[string]bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members := make(map[string]bool, len(m.members)+1)
+	for _, id := range m.members {
+		members[id] = true
+	}
+	fn(members)
+	m.members = m.members[:0]
+	for id := range members {
+		m.members = append(m.members, id)
+	}
+	sort.Strings(m.members)
+	for p := range m.owners {
+		var (
+			owner string
+			max   uint32
+		)
+		for _, id := range m.members {
+			h := fnv.New32a()
+			h.Write([]byte(id))
+			h.Write([]byte{'#'})
+			h.Write([]byte(strconv.Itoa(p)))
+			if w := h.Sum32(); owner == "" || w > max {
+				owner, max = id, w
+			}
+		}
+		m.owners[p] = owner
+	}
+}
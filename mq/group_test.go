@@ -0,0 +1,72 @@
+package mq_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/mq"
+	"github.com/gopherd/doge/service/discovery/memory"
+)
+
+func waitMembers(t *testing.T, n int, members ...*mq.Membership) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for _, m := range members {
+		for len(m.Members()) != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("member %s: want %d members, but got %v", m.ID(), n, m.Members())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestMembership(t *testing.T) {
+	d := memory.New()
+	m1, err := mq.JoinGroup(d, "foo", "g", 0)
+	if err != nil {
+		t.Fatalf("join error: %v", err)
+	}
+	m2, err := mq.JoinGroup(d, "foo", "g", 0)
+	if err != nil {
+		t.Fatalf("join error: %v", err)
+	}
+	other, err := mq.JoinGroup(d, "foo", "other", 0)
+	if err != nil {
+		t.Fatalf("join error: %v", err)
+	}
+	defer other.Leave()
+	waitMembers(t, 2, m1, m2)
+	waitMembers(t, 1, other)
+
+	owned := make(map[*mq.Membership]int)
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		switch {
+		case m1.Owns(key) && !m2.Owns(key):
+			owned[m1]++
+		case m2.Owns(key) && !m1.Owns(key):
+			owned[m2]++
+		default:
+			t.Fatalf("key %s owned by both or none", key)
+		}
+		if !other.Owns(key) {
+			t.Fatalf("key %s not owned by the only member", key)
+		}
+	}
+	if owned[m1] == 0 || owned[m2] == 0 {
+		t.Fatalf("unbalanced partitions: %d vs %d", owned[m1], owned[m2])
+	}
+
+	if err := m2.Leave(); err != nil {
+		t.Fatalf("leave error: %v", err)
+	}
+	waitMembers(t, 1, m1)
+	for i := 0; i < 100; i++ {
+		if !m1.Owns(strconv.Itoa(i)) {
+			t.Fatalf("key %d not owned by the only member", i)
+		}
+	}
+	m1.Leave()
+}
@@ -0,0 +1,390 @@
+// Package memory implements an in-process mq driver registered as "memory".
+//
+// Conns opened with the same source share the same broker, every message
+// published to a topic is delivered to all consumers subscribed the topic.
+// Claims implement mq.AckClaim, messages received from Delivery() and
+// nacked with requeue are redelivered to the same consumer.
+//
+// Conn implements mq.GroupSubscriber, mq.KeyPublisher and mq.Unsubscriber, members of groups
+// are coordinated by the discovery passed to mq.Open (an in-memory discovery
+// of the broker used if it's nil) via mq.Membership.
+//
+//	import _ "github.com/gopherd/doge/mq/memory"
+//
+//	q, err := mq.Open("memory", "cluster1", discovery)
+package memory
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gopherd/doge/mq"
+	"github.com/gopherd/doge/service/discovery"
+	discoverymemory "github.com/gopherd/doge/service/discovery/memory"
+)
+
+// ErrClosed represents an error in case of using a closed conn
+var ErrClosed = errors.New("mq/memory: conn closed")
+
+func init() {
+	mq.Register("memory", driver{})
+}
+
+type driver struct{}
+
+// Open implements mq.Driver Open method
+func (driver) Open(source string, d discovery.Discovery) (mq.Conn, error) {
+	conn := Open(source)
+	if d != nil {
+		conn.discovery = d
+	}
+	return conn, nil
+}
+
+var (
+	mu      sync.Mutex
+	brokers = make(map[string]*broker)
+)
+
+// Open opens a Conn to the shared broker by source
+func Open(source string) *Conn {
+	mu.Lock()
+	b, ok := brokers[source]
+	if !ok {
+		b = newBroker()
+		brokers[source] = b
+	}
+	mu.Unlock()
+	return newConn(b)
+}
+
+// New opens a Conn to a standalone broker which is not shared by source
+func New() *Conn {
+	return newConn(newBroker())
+}
+
+// broker dispatches messages to claims by topic
+type broker struct {
+	nextID    uint64
+	discovery discovery.Discovery // used for conns opened without discovery
+
+	mu     sync.RWMutex
+	topics map[string]map[*claim]struct{}
+}
+
+func newBroker() *broker {
+	return &broker{
+		discovery: discoverymemory.New(),
+		topics:    make(map[string]map[*claim]struct{}),
+	}
+}
+
+func (b *broker) add(topic string, c *claim) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	claims, ok := b.topics[topic]
+	if !ok {
+		claims = make(map[*claim]struct{})
+		b.topics[topic] = claims
+	}
+	claims[c] = struct{}{}
+}
+
+func (b *broker) remove(topic string, c *claim) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if claims, ok := b.topics[topic]; ok {
+		delete(claims, c)
+		if len(claims) == 0 {
+			delete(b.topics, topic)
+		}
+	}
+}
+
+// publish pushes a copy of content to each claim subscribed the topic,
+// claims of group members accept messages of their own partitions only.
+func (b *broker) publish(topic, key string, content []byte) {
+	id := strconv.FormatUint(atomic.AddUint64(&b.nextID, 1), 10)
+	if key == "" {
+		key = id
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for c := range b.topics[topic] {
+		if c.member != nil && !c.member.Owns(key) {
+			continue
+		}
+		c.push(&delivery{
+			claim:   c,
+			id:      id,
+			content: append([]byte(nil), content...),
+		})
+	}
+}
+
+// Conn implements mq.Conn in memory
+type Conn struct {
+	broker    *broker
+	discovery discovery.Discovery
+
+	mu     sync.Mutex
+	closed bool
+	claims map[*claim]string // claim => topic
+	wg     sync.WaitGroup
+}
+
+func newConn(b *broker) *Conn {
+	return &Conn{
+		broker:    b,
+		discovery: b.discovery,
+		claims:    make(map[*claim]string),
+	}
+}
+
+// Close implements mq.Conn Close method, it blocks until all consumers returned.
+func (conn *Conn) Close() error {
+	conn.mu.Lock()
+	if conn.closed {
+		conn.mu.Unlock()
+		return nil
+	}
+	conn.closed = true
+	claims := conn.claims
+	conn.claims = nil
+	conn.mu.Unlock()
+
+	for c, topic := range claims {
+		conn.broker.remove(topic, c)
+		c.close()
+	}
+	conn.wg.Wait()
+	for c := range claims {
+		if c.member != nil {
+			c.member.Leave()
+		}
+	}
+	return nil
+}
+
+// Ping implements mq.Conn Ping method
+func (conn *Conn) Ping(topic string) error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Subscribe implements mq.Conn Subscribe method. Setup of consumer is called
+// before Subscribe returned, and the consumption loop runs in a new goroutine.
+func (conn *Conn) Subscribe(topic string, consumer mq.Consumer) error {
+	return conn.subscribe(topic, nil, consumer)
+}
+
+// SubscribeGroup implements mq.GroupSubscriber SubscribeGroup method
+func (conn *Conn) SubscribeGroup(topic, group string, consumer mq.Consumer) error {
+	if err := conn.Ping(topic); err != nil {
+		return err
+	}
+	member, err := mq.JoinGroup(conn.discovery, topic, group, 0)
+	if err != nil {
+		return err
+	}
+	if err := conn.subscribe(topic, member, consumer); err != nil {
+		member.Leave()
+		return err
+	}
+	return nil
+}
+
+func (conn *Conn) subscribe(topic string, member *mq.Membership, consumer mq.Consumer) error {
+	if err := consumer.Setup(); err != nil {
+		return err
+	}
+	c := newClaim()
+	c.member = member
+	conn.mu.Lock()
+	if conn.closed {
+		conn.mu.Unlock()
+		consumer.Cleanup()
+		return ErrClosed
+	}
+	conn.claims[c] = topic
+	conn.wg.Add(1)
+	conn.mu.Unlock()
+
+	conn.broker.add(topic, c)
+	go c.pump()
+	go func() {
+		defer conn.wg.Done()
+		consumer.Consume(topic, c)
+		consumer.Cleanup()
+	}()
+	return nil
+}
+
+// Unsubscribe implements mq.Unsubscriber Unsubscribe method, it doesn't wait
+// for consumers returned.
+func (conn *Conn) Unsubscribe(topic string) error {
+	conn.mu.Lock()
+	if conn.closed {
+		conn.mu.Unlock()
+		return ErrClosed
+	}
+	var claims []*claim
+	for c, t := range conn.claims {
+		if t == topic {
+			claims = append(claims, c)
+			delete(conn.claims, c)
+		}
+	}
+	conn.mu.Unlock()
+
+	for _, c := range claims {
+		conn.broker.remove(topic, c)
+		c.close()
+		if c.member != nil {
+			c.member.Leave()
+		}
+	}
+	return nil
+}
+
+// Publish implements mq.Conn Publish method
+func (conn *Conn) Publish(topic string, content []byte) error {
+	if err := conn.Ping(topic); err != nil {
+		return err
+	}
+	conn.broker.publish(topic, "", content)
+	return nil
+}
+
+// PublishKey implements mq.KeyPublisher PublishKey method
+func (conn *Conn) PublishKey(topic, key string, content []byte) error {
+	if err := conn.Ping(topic); err != nil {
+		return err
+	}
+	conn.broker.publish(topic, key, content)
+	return nil
+}
+
+// claim implements mq.AckClaim, messages are queued without blocking publishers
+type claim struct {
+	member *mq.Membership // nil if not a group member
+
+	errc chan error
+	msgc chan []byte
+	delc chan mq.Delivery
+
+	mu       sync.Mutex
+	queue    []*delivery
+	notified chan struct{}
+	quit     chan struct{}
+}
+
+func newClaim() *claim {
+	return &claim{
+		errc:     make(chan error, 1),
+		msgc:     make(chan []byte),
+		delc:     make(chan mq.Delivery),
+		notified: make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+	}
+}
+
+// Err implements mq.Claim Err method, the channel is closed after the conn closed
+func (c *claim) Err() <-chan error {
+	return c.errc
+}
+
+// Message implements mq.Claim Message method
+func (c *claim) Message() <-chan []byte {
+	return c.msgc
+}
+
+// Delivery implements mq.AckClaim Delivery method
+func (c *claim) Delivery() <-chan mq.Delivery {
+	return c.delc
+}
+
+func (c *claim) push(d *delivery) {
+	c.mu.Lock()
+	c.queue = append(c.queue, d)
+	c.mu.Unlock()
+	select {
+	case c.notified <- struct{}{}:
+	default:
+	}
+}
+
+func (c *claim) close() {
+	close(c.quit)
+}
+
+func (c *claim) pump() {
+	defer close(c.errc)
+	for {
+		c.mu.Lock()
+		queue := c.queue
+		c.queue = nil
+		c.mu.Unlock()
+		for _, d := range queue {
+			select {
+			case c.msgc <- d.content:
+			case c.delc <- d:
+			case <-c.quit:
+				return
+			}
+		}
+		select {
+		case <-c.notified:
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// delivery implements mq.Delivery
+type delivery struct {
+	claim       *claim
+	id          string
+	content     []byte
+	redelivered int
+	acked       int32
+}
+
+// ID implements mq.Delivery ID method
+func (d *delivery) ID() string { return d.id }
+
+// Content implements mq.Delivery Content method
+func (d *delivery) Content() []byte { return d.content }
+
+// Redelivered implements mq.Delivery Redelivered method
+func (d *delivery) Redelivered() int { return d.redelivered }
+
+// Ack implements mq.Delivery Ack method
+func (d *delivery) Ack() error {
+	if !atomic.CompareAndSwapInt32(&d.acked, 0, 1) {
+		return mq.ErrAcknowledged
+	}
+	return nil
+}
+
+// Nack implements mq.Delivery Nack method
+func (d *delivery) Nack(requeue bool) error {
+	if !atomic.CompareAndSwapInt32(&d.acked, 0, 1) {
+		return mq.ErrAcknowledged
+	}
+	if requeue {
+		d.claim.push(&delivery{
+			claim:       d.claim,
+			id:          d.id,
+			content:     d.content,
+			redelivered: d.redelivered + 1,
+		})
+	}
+	return nil
+}
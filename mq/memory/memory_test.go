@@ -0,0 +1,278 @@
+package memory_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/mq"
+	"github.com/gopherd/doge/mq/memory"
+	discoverymemory "github.com/gopherd/doge/service/discovery/memory"
+)
+
+type consumer struct {
+	mq.FuncConsumer
+	setup, cleanup bool
+}
+
+func (c *consumer) Setup() error {
+	c.setup = true
+	return nil
+}
+
+func (c *consumer) Cleanup() error {
+	c.cleanup = true
+	return nil
+}
+
+func TestFanOut(t *testing.T) {
+	pub, err := mq.Open("memory", "TestFanOut", nil)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	sub := memory.Open("TestFanOut")
+
+	var (
+		mu       sync.Mutex
+		received = make(map[int][]string)
+		wg       sync.WaitGroup
+	)
+	consumers := make([]*consumer, 2)
+	for i := range consumers {
+		i := i
+		consumers[i] = &consumer{FuncConsumer: func(topic string, msg []byte, err error) {
+			if err != nil {
+				t.Errorf("consumer %d received error: %v", i, err)
+				return
+			}
+			mu.Lock()
+			received[i] = append(received[i], string(msg))
+			mu.Unlock()
+			wg.Done()
+		}}
+		if err := sub.Subscribe("foo", consumers[i]); err != nil {
+			t.Fatalf("subscribe error: %v", err)
+		}
+		if !consumers[i].setup {
+			t.Fatalf("consumer %d not setup", i)
+		}
+	}
+
+	wg.Add(2 * 3)
+	for _, s := range []string{"a", "b", "c"} {
+		content := []byte(s)
+		if err := pub.Publish("foo", content); err != nil {
+			t.Fatalf("publish error: %v", err)
+		}
+		content[0] = 'x'
+	}
+	pub.Publish("bar", []byte("d"))
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("timeout, received %v", received)
+	}
+
+	if err := sub.Close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	for i, c := range consumers {
+		if got := received[i]; len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "c" {
+			t.Fatalf("consumer %d received %v", i, got)
+		}
+		if !c.cleanup {
+			t.Fatalf("consumer %d not cleanup", i)
+		}
+	}
+	if err := sub.Publish("foo", []byte("e")); err != memory.ErrClosed {
+		t.Fatalf("want ErrClosed, but got %v", err)
+	}
+	if err := pub.Publish("foo", []byte("f")); err != nil {
+		t.Fatalf("publish error: %v", err)
+	}
+	pub.Close()
+}
+
+func TestRedelivery(t *testing.T) {
+	conn := memory.New()
+	defer conn.Close()
+
+	type received struct {
+		id          string
+		content     string
+		redelivered int
+	}
+	ch := make(chan received, 4)
+	err := conn.Subscribe("foo", mq.FuncDeliveryConsumer(func(topic string, d mq.Delivery, err error) {
+		if err != nil {
+			t.Errorf("received error: %v", err)
+			return
+		}
+		ch <- received{d.ID(), string(d.Content()), d.Redelivered()}
+		if d.Redelivered() < 2 {
+			d.Nack(true)
+		} else {
+			d.Ack()
+		}
+		if err := d.Ack(); err != mq.ErrAcknowledged {
+			t.Errorf("want ErrAcknowledged, but got %v", err)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("subscribe error: %v", err)
+	}
+	conn.Publish("foo", []byte("a"))
+
+	var id string
+	for i := 0; i < 3; i++ {
+		select {
+		case r := <-ch:
+			if r.content != "a" || r.redelivered != i {
+				t.Fatalf("#%d: unexpected delivery %+v", i, r)
+			}
+			if i == 0 {
+				id = r.id
+			} else if r.id != id {
+				t.Fatalf("#%d: want id %q, but got %q", i, id, r.id)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("#%d: timeout", i)
+		}
+	}
+	select {
+	case r := <-ch:
+		t.Fatalf("unexpected delivery %+v after acked", r)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestGroup(t *testing.T) {
+	d := discoverymemory.New()
+	conns := make([]mq.Conn, 3)
+	for i := range conns {
+		conn, err := mq.Open("memory", "TestGroup", d)
+		if err != nil {
+			t.Fatalf("open error: %v", err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+	}
+
+	type received struct {
+		member  int
+		content string
+	}
+	ch := make(chan received, 1024)
+	for i := 0; i < 2; i++ {
+		i := i
+		err := mq.SubscribeGroup(conns[i], "foo", "g", mq.FuncConsumer(func(topic string, msg []byte, err error) {
+			if err == nil {
+				ch <- received{i, string(msg)}
+			}
+		}))
+		if err != nil {
+			t.Fatalf("subscribe group error: %v", err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		all, _ := d.ResolveAll(context.Background(), "mq/group/foo/g")
+		if len(all) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("want 2 members, but got %v", all)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// waiting for membership views converged
+	time.Sleep(50 * time.Millisecond)
+
+	const keys, n = 10, 10
+	for i := 0; i < n; i++ {
+		for k := 0; k < keys; k++ {
+			mq.PublishKey(conns[2], "foo", strconv.Itoa(k), []byte(strconv.Itoa(k)+":"+strconv.Itoa(i)))
+		}
+	}
+	var (
+		owners = make(map[string]int)
+		next   = make(map[string]int)
+	)
+	for i := 0; i < keys*n; i++ {
+		select {
+		case r := <-ch:
+			parts := strings.Split(r.content, ":")
+			key, seq := parts[0], parts[1]
+			if owner, ok := owners[key]; ok && owner != r.member {
+				t.Fatalf("key %s received by member %d and %d", key, owner, r.member)
+			}
+			owners[key] = r.member
+			if seq != strconv.Itoa(next[key]) {
+				t.Fatalf("key %s: want seq %d, but got %s", key, next[key], seq)
+			}
+			next[key]++
+		case <-time.After(time.Second):
+			t.Fatalf("#%d: timeout", i)
+		}
+	}
+	select {
+	case r := <-ch:
+		t.Fatalf("unexpected message %+v", r)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	conn := memory.New()
+	defer conn.Close()
+
+	received := make(chan string, 4)
+	done := make(chan struct{})
+	fc := mq.FuncConsumer(func(topic string, msg []byte, err error) {
+		received <- topic + ":" + string(msg)
+	})
+	if err := conn.Subscribe("foo", fc); err != nil {
+		t.Fatalf("subscribe error: %v", err)
+	}
+	if err := conn.Subscribe("bar", &unsubscribed{FuncConsumer: fc, done: done}); err != nil {
+		t.Fatalf("subscribe error: %v", err)
+	}
+	if err := mq.Unsubscribe(conn, "bar"); err != nil {
+		t.Fatalf("unsubscribe error: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("consumer of unsubscribed topic not returned")
+	}
+	conn.Publish("bar", []byte("a"))
+	conn.Publish("foo", []byte("b"))
+	select {
+	case got := <-received:
+		if got != "foo:b" {
+			t.Fatalf("unexpected message %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+// unsubscribed closes done after the consumption loop returned
+type unsubscribed struct {
+	mq.FuncConsumer
+	done chan struct{}
+}
+
+func (c *unsubscribed) Cleanup() error {
+	close(c.done)
+	return nil
+}
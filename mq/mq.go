@@ -1,6 +1,7 @@
 package mq
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 
@@ -25,6 +26,34 @@ type Claim interface {
 	Message() <-chan []byte
 }
 
+// ErrAcknowledged represents an error in case of acknowledging a delivery twice
+var ErrAcknowledged = errors.New("mq: delivery already acknowledged")
+
+// Delivery represents a received message which should be acknowledged
+type Delivery interface {
+	// ID returns the unique id of message
+	ID() string
+	// Content returns the message content
+	Content() []byte
+	// Redelivered returns the number of times the message has been redelivered
+	Redelivered() int
+	// Ack acknowledges that the message has been processed successfully
+	Ack() error
+	// Nack reports that the message processing failed, the message
+	// would be redelivered later if requeue is true.
+	Nack(requeue bool) error
+}
+
+// AckClaim is an optional interface which could be implemented by Claim
+// of drivers supporting at-least-once delivery. Each message is sent to
+// either Message() or Delivery(), messages received from Message() are
+// acknowledged automatically.
+type AckClaim interface {
+	Claim
+	// Delivery chan used to receive messages which should be acknowledged
+	Delivery() <-chan Delivery
+}
+
 // Conn is the top-level mq connection
 type Conn interface {
 	// Close closes the conn
@@ -38,6 +67,29 @@ type Conn interface {
 	Publish(topic string, content []byte) error
 }
 
+// ErrUnsubscribeUnsupported represents an error in case of the conn doesn't
+// support unsubscribing
+var ErrUnsubscribeUnsupported = errors.New("mq: unsubscribe unsupported")
+
+// Unsubscriber is an optional interface which could be implemented by Conn
+// to cancel subscriptions before the conn closed.
+type Unsubscriber interface {
+	// Unsubscribe cancels all subscriptions of topic by the conn, including
+	// subscriptions as members of groups. Consumption loops of the
+	// subscriptions return as if the conn closed.
+	Unsubscribe(topic string) error
+}
+
+// Unsubscribe cancels all subscriptions of topic by conn, ErrUnsubscribeUnsupported
+// returned if conn doesn't implement Unsubscriber, then subscriptions live as
+// long as the conn.
+func Unsubscribe(conn Conn, topic string) error {
+	if u, ok := conn.(Unsubscriber); ok {
+		return u.Unsubscribe(topic)
+	}
+	return ErrUnsubscribeUnsupported
+}
+
 // Driver is the interface that must be implemented by a mq driver
 type Driver interface {
 	// Open returns a Conn instance by a driver-specific source name
@@ -99,3 +151,47 @@ func (fc FuncConsumer) Consume(topic string, claim Claim) {
 		}
 	}
 }
+
+// FuncDeliveryConsumer implements Consumer interface which receives deliveries.
+// If the claim doesn't implement AckClaim, messages are wrapped as deliveries
+// whose Ack and Nack do nothing.
+type FuncDeliveryConsumer func(topic string, d Delivery, err error)
+
+// Setup implements Consumer Setup method
+func (fc FuncDeliveryConsumer) Setup() error { return nil }
+
+// Cleanup implements Consumer Cleanup method
+func (fc FuncDeliveryConsumer) Cleanup() error { return nil }
+
+// Consume implements Consumer Consume method
+func (fc FuncDeliveryConsumer) Consume(topic string, claim Claim) {
+	errChan := claim.Err()
+	msgChan := claim.Message()
+	var deliveryChan <-chan Delivery
+	if ac, ok := claim.(AckClaim); ok {
+		deliveryChan = ac.Delivery()
+		msgChan = nil
+	}
+	for {
+		select {
+		case err := <-errChan:
+			if err != nil {
+				fc(topic, nil, err)
+			}
+			return
+		case msg := <-msgChan:
+			fc(topic, autoAck(msg), nil)
+		case d := <-deliveryChan:
+			fc(topic, d, nil)
+		}
+	}
+}
+
+// autoAck wraps message content as a Delivery which acknowledged automatically
+type autoAck []byte
+
+func (d autoAck) ID() string              { return "" }
+func (d autoAck) Content() []byte         { return d }
+func (d autoAck) Redelivered() int        { return 0 }
+func (d autoAck) Ack() error              { return nil }
+func (d autoAck) Nack(requeue bool) error { return nil }
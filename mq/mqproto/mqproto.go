@@ -0,0 +1,144 @@
+// Package mqproto publishes and consumes proto.Message values over mq.Conn.
+//
+// Messages are encoded with type and size like proto.Encode:
+//
+//	|type|body.size|body|
+//
+// and received messages are dispatched to a proto.Dispatcher:
+//
+//	var dispatcher proto.Dispatcher
+//	dispatcher.AddListener(proto.Listen(func(m *foo.Bar, args ...any) {
+//		topic := args[0].(string)
+//		ctx := args[1].(context.Context)
+//		...
+//	}))
+//	mqproto.Subscribe(conn, "foo", &dispatcher)
+//	mqproto.Publish(conn, "foo", &foo.Bar{})
+//
+// Messages published by PublishContext are wrapped in mq envelopes with the
+// trace context, which is passed to listeners by the context argument.
+package mqproto
+
+import (
+	"context"
+
+	"github.com/gopherd/doge/mq"
+	"github.com/gopherd/doge/proto"
+)
+
+// Publish encodes m and publishes it to topic
+func Publish(conn mq.Conn, topic string, m proto.Message) error {
+	return PublishContext(context.Background(), conn, topic, m)
+}
+
+// PublishContext encodes m and publishes it to topic with the trace context in ctx
+func PublishContext(ctx context.Context, conn mq.Conn, topic string, m proto.Message) error {
+	buf, err := proto.Encode(m, 0)
+	if err != nil {
+		return err
+	}
+	return mq.PublishContext(ctx, conn, topic, buf)
+}
+
+// Subscribe subscribes topic and dispatches received messages to dispatcher
+func Subscribe(conn mq.Conn, topic string, dispatcher *proto.Dispatcher, options ...Option) error {
+	return conn.Subscribe(topic, NewConsumer(dispatcher, options...))
+}
+
+// Option represents options of NewConsumer
+type Option func(*option)
+
+type option struct {
+	arena        proto.Arena
+	errorHandler func(topic string, err error)
+}
+
+// WithArena specify the arena to create received messages, messages are put
+// back to the arena after dispatched. So listeners MUST NOT retain messages
+// if an arena specified.
+func WithArena(arena proto.Arena) Option {
+	return func(opt *option) {
+		opt.arena = arena
+	}
+}
+
+// WithErrorHandler specify the handler of errors received from mq or
+// occurred while decoding messages
+func WithErrorHandler(errorHandler func(topic string, err error)) Option {
+	return func(opt *option) {
+		opt.errorHandler = errorHandler
+	}
+}
+
+// Consumer implements mq.Consumer which decodes received content as messages
+// and dispatches them to the dispatcher. The topic is passed to listeners as
+// the first argument, and the context carrying the trace context of envelope
+// as the second argument.
+//
+// The dispatcher may be fired by multiple consumption loops concurrently if
+// it's shared by subscriptions, so listeners should not be added or removed
+// after subscribed.
+type Consumer struct {
+	dispatcher *proto.Dispatcher
+	opt        option
+}
+
+// NewConsumer creates a Consumer
+func NewConsumer(dispatcher *proto.Dispatcher, options ...Option) *Consumer {
+	c := &Consumer{dispatcher: dispatcher}
+	for i := range options {
+		options[i](&c.opt)
+	}
+	return c
+}
+
+// Setup implements mq.Consumer Setup method
+func (c *Consumer) Setup() error { return nil }
+
+// Cleanup implements mq.Consumer Cleanup method
+func (c *Consumer) Cleanup() error { return nil }
+
+// Consume implements mq.Consumer Consume method
+func (c *Consumer) Consume(topic string, claim mq.Claim) {
+	errChan := claim.Err()
+	msgChan := claim.Message()
+	for {
+		select {
+		case err := <-errChan:
+			if err != nil {
+				c.onError(topic, err)
+			}
+			return
+		case content := <-msgChan:
+			if err := c.dispatch(topic, content); err != nil {
+				c.onError(topic, err)
+			}
+		}
+	}
+}
+
+func (c *Consumer) onError(topic string, err error) {
+	if c.opt.errorHandler != nil {
+		c.opt.errorHandler(topic, err)
+	}
+}
+
+// dispatch decodes all messages in content and dispatches them
+func (c *Consumer) dispatch(topic string, content []byte) error {
+	ctx, content := mq.UnwrapEnvelope(context.Background(), content)
+	for len(content) > 0 {
+		n, m, err := proto.Decode(content, c.opt.arena)
+		if err != nil {
+			if m != nil && c.opt.arena != nil {
+				c.opt.arena.Put(m)
+			}
+			return err
+		}
+		content = content[n:]
+		c.dispatcher.Fire(m, topic, ctx)
+		if c.opt.arena != nil {
+			c.opt.arena.Put(m)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package mqproto_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/mq/memory"
+	"github.com/gopherd/doge/mq/mqproto"
+	"github.com/gopherd/doge/proto"
+)
+
+const textType = 1001
+
+type text struct {
+	s string
+}
+
+func (m *text) Typeof() proto.Type { return textType }
+func (m *text) Sizeof() int        { return len(m.s) }
+func (m *text) Nameof() string     { return "text" }
+
+func (m *text) MarshalAppend(buf []byte, useCachedSize bool) ([]byte, error) {
+	return append(buf, m.s...), nil
+}
+
+func (m *text) Unmarshal(buf []byte) error {
+	m.s = string(buf)
+	return nil
+}
+
+func init() {
+	proto.Register("mqproto_test", textType, func() proto.Message { return new(text) })
+}
+
+func TestPubSub(t *testing.T) {
+	conn := memory.New()
+	defer conn.Close()
+
+	var (
+		pool       proto.Pool
+		dispatcher proto.Dispatcher
+		received   = make(chan string, 4)
+		errors     = make(chan error, 4)
+	)
+	dispatcher.AddListener(proto.Listen(func(m *text, args ...any) {
+		received <- args[0].(string) + ":" + m.s
+	}))
+	err := mqproto.Subscribe(conn, "foo", &dispatcher,
+		mqproto.WithArena(&pool),
+		mqproto.WithErrorHandler(func(topic string, err error) {
+			errors <- err
+		}),
+	)
+	if err != nil {
+		t.Fatalf("subscribe error: %v", err)
+	}
+	for _, s := range []string{"hello", "world"} {
+		if err := mqproto.Publish(conn, "foo", &text{s: s}); err != nil {
+			t.Fatalf("publish error: %v", err)
+		}
+	}
+	unknown := make([]byte, 8)
+	n := proto.EncodeType(unknown, textType+1)
+	n += proto.EncodeSize(unknown[n:], 0)
+	conn.Publish("foo", unknown[:n])
+
+	for _, want := range []string{"foo:hello", "foo:world"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("want %q, but got %q", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("want %q, but timeout", want)
+		}
+	}
+	select {
+	case err := <-errors:
+		if _, ok := err.(*proto.UnrecognizedTypeError); !ok {
+			t.Fatalf("want UnrecognizedTypeError, but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("want error, but timeout")
+	}
+}
@@ -0,0 +1,260 @@
+// Package mqrpc implements request/reply over mq.Conn.
+//
+// A Client subscribes a unique reply topic, and publishes requests to the topic
+// of service with the reply topic and a correlation id. The server replies to the
+// reply topic with the same correlation id, so that the reply is routed to the
+// right caller.
+//
+//	// server side
+//	mqrpc.Serve(conn, "user", mqrpc.HandlerFunc(func(ctx context.Context, req []byte) ([]byte, error) {
+//		return handle(req)
+//	}))
+//
+//	// client side
+//	client, err := mqrpc.NewClient(conn)
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//	defer cancel()
+//	reply, err := client.Call(ctx, "user", req)
+//
+// The trace context of ctx passed to Call is propagated to the ctx of handler.
+package mqrpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gopherd/doge/internal/uuid"
+	"github.com/gopherd/doge/mq"
+)
+
+var (
+	ErrClosed          = errors.New("mqrpc: client closed")
+	ErrMalformedPacket = errors.New("mqrpc: malformed packet")
+)
+
+// RemoteError represents an error returned by the handler of server
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return "mqrpc: remote error: " + e.Message
+}
+
+const (
+	kindRequest byte = iota + 1
+	kindReply
+	kindError
+)
+
+// packet format:
+//
+//	|kind(1 byte)|id(uvarint)|replyTo.size(uvarint)|replyTo|payload|
+type packet struct {
+	kind    byte
+	id      uint64
+	replyTo string
+	payload []byte
+}
+
+func (p packet) encode() []byte {
+	buf := make([]byte, 1+binary.MaxVarintLen64*2+len(p.replyTo)+len(p.payload))
+	buf[0] = p.kind
+	off := 1
+	off += binary.PutUvarint(buf[off:], p.id)
+	off += binary.PutUvarint(buf[off:], uint64(len(p.replyTo)))
+	off += copy(buf[off:], p.replyTo)
+	off += copy(buf[off:], p.payload)
+	return buf[:off]
+}
+
+func (p *packet) decode(buf []byte) error {
+	if len(buf) == 0 {
+		return ErrMalformedPacket
+	}
+	p.kind = buf[0]
+	off := 1
+	id, n := binary.Uvarint(buf[off:])
+	if n <= 0 {
+		return ErrMalformedPacket
+	}
+	p.id = id
+	off += n
+	size, n := binary.Uvarint(buf[off:])
+	if n <= 0 || size > uint64(len(buf)-off-n) {
+		return ErrMalformedPacket
+	}
+	off += n
+	p.replyTo = string(buf[off : off+int(size)])
+	off += int(size)
+	p.payload = buf[off:]
+	return nil
+}
+
+// Handler handles requests
+type Handler interface {
+	ServeRequest(ctx context.Context, request []byte) ([]byte, error)
+}
+
+// HandlerFunc wraps function as a Handler
+type HandlerFunc func(ctx context.Context, request []byte) ([]byte, error)
+
+// ServeRequest implements Handler ServeRequest method
+func (fn HandlerFunc) ServeRequest(ctx context.Context, request []byte) ([]byte, error) {
+	return fn(ctx, request)
+}
+
+// Serve subscribes topic and replies requests by handler. Each request is
+// handled in a new goroutine, so the handler must be concurrent-safe.
+func Serve(conn mq.Conn, topic string, handler Handler) error {
+	return conn.Subscribe(topic, mq.FuncConsumer(func(topic string, msg []byte, err error) {
+		if err != nil {
+			return
+		}
+		var req packet
+		if req.decode(msg) != nil || req.kind != kindRequest || req.replyTo == "" {
+			return
+		}
+		go func() {
+			reply := packet{kind: kindReply, id: req.id}
+			ctx, payload := mq.UnwrapEnvelope(context.Background(), req.payload)
+			payload, err := handler.ServeRequest(ctx, payload)
+			if err != nil {
+				reply.kind = kindError
+				reply.payload = []byte(err.Error())
+			} else {
+				reply.payload = payload
+			}
+			conn.Publish(req.replyTo, reply.encode())
+		}()
+	}))
+}
+
+type result struct {
+	reply []byte
+	err   error
+}
+
+// Client calls remote handlers via mq
+type Client struct {
+	conn       mq.Conn
+	replyTopic string
+	nextID     uint64
+
+	mu      sync.Mutex
+	closed  bool
+	pending map[uint64]chan result
+}
+
+// NewClient creates a Client with a unique reply topic
+func NewClient(conn mq.Conn) (*Client, error) {
+	return NewClientWithReplyTopic(conn, "mqrpc/reply/"+uuid.NewString())
+}
+
+// NewClientWithReplyTopic creates a Client which receives replies from replyTopic.
+// The replyTopic MUST be unique for each client.
+func NewClientWithReplyTopic(conn mq.Conn, replyTopic string) (*Client, error) {
+	c := &Client{
+		conn:       conn,
+		replyTopic: replyTopic,
+		pending:    make(map[uint64]chan result),
+	}
+	if err := conn.Subscribe(replyTopic, mq.FuncConsumer(c.onReply)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ReplyTopic returns the reply topic of client
+func (c *Client) ReplyTopic() string {
+	return c.replyTopic
+}
+
+// Close fails all pending calls with ErrClosed, and unsubscribes the reply
+// topic if the conn implements mq.Unsubscriber, otherwise the subscription
+// lives as long as the conn.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	for id, ch := range c.pending {
+		ch <- result{err: ErrClosed}
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+	if err := mq.Unsubscribe(c.conn, c.replyTopic); err != nil && !errors.Is(err, mq.ErrUnsubscribeUnsupported) {
+		return err
+	}
+	return nil
+}
+
+func (c *Client) onReply(topic string, msg []byte, err error) {
+	if err != nil {
+		return
+	}
+	var reply packet
+	if reply.decode(msg) != nil {
+		return
+	}
+	c.mu.Lock()
+	ch, ok := c.pending[reply.id]
+	if ok {
+		delete(c.pending, reply.id)
+	}
+	c.mu.Unlock()
+	if !ok {
+		// the call has been timeout
+		return
+	}
+	switch reply.kind {
+	case kindReply:
+		ch <- result{reply: reply.payload}
+	case kindError:
+		ch <- result{err: &RemoteError{Message: string(reply.payload)}}
+	default:
+		ch <- result{err: ErrMalformedPacket}
+	}
+}
+
+// Call publishes the request to topic and waits the reply until ctx done
+func (c *Client) Call(ctx context.Context, topic string, request []byte) ([]byte, error) {
+	id := atomic.AddUint64(&c.nextID, 1)
+	ch := make(chan result, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	req := packet{
+		kind:    kindRequest,
+		id:      id,
+		replyTo: c.replyTopic,
+		payload: mq.WrapEnvelope(ctx, request),
+	}
+	if err := c.conn.Publish(topic, req.encode()); err != nil {
+		c.cancel(id)
+		return nil, err
+	}
+	select {
+	case r := <-ch:
+		return r.reply, r.err
+	case <-ctx.Done():
+		c.cancel(id)
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Client) cancel(id uint64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
@@ -0,0 +1,67 @@
+package mqrpc_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/mq/memory"
+	"github.com/gopherd/doge/mq/mqrpc"
+)
+
+func TestCall(t *testing.T) {
+	conn := memory.New()
+	defer conn.Close()
+
+	err := mqrpc.Serve(conn, "echo", mqrpc.HandlerFunc(func(ctx context.Context, req []byte) ([]byte, error) {
+		switch string(req) {
+		case "error":
+			return nil, errors.New("bad request")
+		case "sleep":
+			time.Sleep(100 * time.Millisecond)
+		}
+		return append([]byte("echo:"), req...), nil
+	}))
+	if err != nil {
+		t.Fatalf("serve error: %v", err)
+	}
+	client, err := mqrpc.NewClient(conn)
+	if err != nil {
+		t.Fatalf("new client error: %v", err)
+	}
+	defer client.Close()
+
+	const n = 2000
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			req := strconv.Itoa(i)
+			reply, err := client.Call(ctx, "echo", []byte(req))
+			if err != nil {
+				t.Errorf("call %d error: %v", i, err)
+			} else if string(reply) != "echo:"+req {
+				t.Errorf("call %d got reply %q", i, reply)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	ctx := context.Background()
+	var remoteErr *mqrpc.RemoteError
+	if _, err := client.Call(ctx, "echo", []byte("error")); !errors.As(err, &remoteErr) || remoteErr.Message != "bad request" {
+		t.Fatalf("want remote error, but got %v", err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.Call(timeoutCtx, "echo", []byte("sleep")); err != context.DeadlineExceeded {
+		t.Fatalf("want DeadlineExceeded, but got %v", err)
+	}
+}
@@ -7,9 +7,16 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/gopherd/doge/metrics"
 	"github.com/gopherd/doge/net/netutil"
 )
 
+var httpHandling = metrics.MustRegister(metrics.NewGaugeVec(
+	"doge_http_server_handling",
+	"Number of requests being handled by http server",
+	"address",
+))
+
 var pong = []byte{'p', 'o', 'n', 'g'}
 
 func PingHandler(w http.ResponseWriter, r *http.Request) {
@@ -120,9 +127,14 @@ func (httpd *HTTPServer) Handle(pattern string, handler http.Handler, middleware
 	for _, m := range middlewares {
 		handler = m.Apply(handler)
 	}
+	handling := httpHandling.With(httpd.cfg.Address)
 	httpd.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
 		atomic.AddInt64(&httpd.numHandling, 1)
-		defer atomic.AddInt64(&httpd.numHandling, -1)
+		handling.Inc()
+		defer func() {
+			atomic.AddInt64(&httpd.numHandling, -1)
+			handling.Dec()
+		}()
 		if httpd.cfg.Headers != nil {
 			for k, v := range httpd.cfg.Headers {
 				w.Header().Add(k, v)
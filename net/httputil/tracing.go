@@ -0,0 +1,129 @@
+package httputil
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gopherd/doge/tracing"
+)
+
+// statusRecorder records status code written to the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the underlying ResponseWriter, it's used by http.ResponseController
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush implements http.Flusher Flush method
+func (w *statusRecorder) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// hijackRecorder is a statusRecorder which implements http.Hijacker
+type hijackRecorder struct {
+	*statusRecorder
+}
+
+// Hijack implements http.Hijacker Hijack method
+func (w hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// pushRecorder is a statusRecorder which implements http.Pusher
+type pushRecorder struct {
+	*statusRecorder
+}
+
+// Push implements http.Pusher Push method
+func (w pushRecorder) Push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+// hijackPushRecorder is a statusRecorder which implements both http.Hijacker
+// and http.Pusher
+type hijackPushRecorder struct {
+	*statusRecorder
+}
+
+// Hijack implements http.Hijacker Hijack method
+func (w hijackPushRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// Push implements http.Pusher Push method
+func (w hijackPushRecorder) Push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+// recordStatus wraps w as a statusRecorder, the returned ResponseWriter
+// implements http.Hijacker and http.Pusher only if w implements them.
+func recordStatus(w http.ResponseWriter) (http.ResponseWriter, *statusRecorder) {
+	rec := &statusRecorder{ResponseWriter: w}
+	_, hijacker := w.(http.Hijacker)
+	_, pusher := w.(http.Pusher)
+	switch {
+	case hijacker && pusher:
+		return hijackPushRecorder{rec}, rec
+	case hijacker:
+		return hijackRecorder{rec}, rec
+	case pusher:
+		return pushRecorder{rec}, rec
+	default:
+		return rec, rec
+	}
+}
+
+// Tracing returns a Middleware which extracts the trace context from the
+// traceparent header of request, starts a span for each request, and injects
+// the span context to the traceparent header of response. Handlers could get
+// the span by tracing.SpanFromContext(r.Context()). tracing.DefaultTracer used
+// if tracer is nil.
+//
+// Use tracing.Inject to propagate the trace context to outgoing requests.
+func Tracing(tracer *tracing.Tracer) Middleware {
+	if tracer == nil {
+		tracer = tracing.DefaultTracer
+	}
+	return MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := tracing.Extract(r.Context(), r.Header)
+			ctx, span := tracer.Start(ctx, r.Method+" "+r.URL.Path)
+			defer span.End()
+			span.SetAttribute("http.method", r.Method)
+			span.SetAttribute("http.path", r.URL.Path)
+			tracing.Inject(ctx, w.Header())
+
+			w, rec := recordStatus(w)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			span.SetAttribute("http.status_code", strconv.Itoa(rec.status))
+		})
+	})
+}
@@ -0,0 +1,81 @@
+package httputil
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gopherd/doge/tracing"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracing.NewMemoryExporter()
+	tracer := tracing.NewTracer(exporter)
+
+	var got tracing.SpanContext
+	handler := Tracing(tracer).Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = tracing.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	ctx, client := tracer.Start(context.Background(), "client")
+	r := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	tracing.Inject(ctx, r.Header)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Context() != got || span.Parent() != client.Context().SpanID {
+		t.Errorf("server span is not linked to client span")
+	}
+	if span.Name() != "GET /foo" || span.Attributes()["http.status_code"] != "418" {
+		t.Errorf("unexpected span: %s %v", span.Name(), span.Attributes())
+	}
+	if w.Header().Get(tracing.TraceparentHeader) != got.Traceparent() {
+		t.Errorf("traceparent not injected to response")
+	}
+}
+
+// hijackWriter is a ResponseWriter which implements http.Hijacker
+type hijackWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrNotSupported
+}
+
+func TestTracingResponseWriter(t *testing.T) {
+	exporter := tracing.NewMemoryExporter()
+	var hijacker, pusher bool
+	handler := Tracing(tracing.NewTracer(exporter)).Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hijacker = w.(http.Hijacker)
+		_, pusher = w.(http.Pusher)
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("flush error: %v", err)
+		}
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !w.Flushed {
+		t.Error("response not flushed")
+	}
+	if hijacker || pusher {
+		t.Errorf("want neither http.Hijacker nor http.Pusher, got hijacker=%v pusher=%v", hijacker, pusher)
+	}
+	if spans := exporter.Spans(); len(spans) != 1 || spans[0].Attributes()["http.status_code"] != "200" {
+		t.Errorf("unexpected spans %v", spans)
+	}
+
+	handler.ServeHTTP(hijackWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !hijacker || pusher {
+		t.Errorf("want http.Hijacker only, got hijacker=%v pusher=%v", hijacker, pusher)
+	}
+}
@@ -2,6 +2,8 @@ package netutil
 
 import (
 	"bufio"
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -16,9 +18,11 @@ import (
 	"time"
 
 	"github.com/gopherd/doge/io/pagebuf"
+	"github.com/gopherd/doge/metrics"
 	"github.com/gopherd/doge/proto"
 	"github.com/gopherd/doge/text/resp"
 	"github.com/gopherd/doge/text/shell"
+	"github.com/gopherd/doge/tracing"
 	"github.com/gopherd/log"
 )
 
@@ -27,11 +31,50 @@ const (
 	MaxContentLength = 1 << 30
 
 	hello = "hello"
+	ping  = "ping"
+	pong  = "pong"
 )
 
+// kinds of heartbeat messages
+const (
+	heartbeatPing = 0
+	heartbeatPong = 1
+
+	// size of heartbeat message body: 1 byte kind + 8 bytes timestamp
+	heartbeatSize = 9
+)
+
+// kinds of handshaked sessions which heartbeat pings are sent to
+const (
+	pingNone   = 0
+	pingBinary = 1 // proto.HeartbeatType messages
+	pingText   = 2 // "+PING" commands
+)
+
+// metrics of all sessions
 var (
-	ErrNotHandshaked  = errors.New("hello command required")
-	ErrInvalidCommand = errors.New("invalid command")
+	sessionReadBytes = metrics.MustRegister(metrics.NewCounter(
+		"doge_session_read_bytes_total",
+		"Number of bytes read by sessions",
+	))
+	sessionWrittenBytes = metrics.MustRegister(metrics.NewCounter(
+		"doge_session_written_bytes_total",
+		"Number of bytes written by sessions",
+	))
+	sessionReceivedMessages = metrics.MustRegister(metrics.NewCounter(
+		"doge_session_received_messages_total",
+		"Number of messages and commands received by sessions",
+	))
+	sessionSentMessages = metrics.MustRegister(metrics.NewCounter(
+		"doge_session_sent_messages_total",
+		"Number of messages written to sessions, each Write call is counted as a message",
+	))
+)
+
+var (
+	ErrNotHandshaked    = errors.New("hello command required")
+	ErrInvalidCommand   = errors.New("invalid command")
+	ErrHeartbeatTimeout = errors.New("heartbeat timeout")
 )
 
 // errno returns v's underlying uintptr, else 0.
@@ -90,6 +133,8 @@ func IsNetworkError(err error) bool {
 type timeoutReader struct {
 	conn    net.Conn
 	timeout time.Duration
+	closed  *int32 // closed flag of session
+	read    *int64 // number of bytes read by session
 }
 
 // Read implements io.Reader Read method
@@ -97,7 +142,17 @@ func (tr *timeoutReader) Read(p []byte) (n int, err error) {
 	if tr.timeout > 0 {
 		tr.conn.SetReadDeadline(time.Now().Add(tr.timeout))
 	}
-	return tr.conn.Read(p)
+	// checked after the deadline set, so that the deadline set by
+	// Session.Close is never overridden by a blocking read
+	if atomic.LoadInt32(tr.closed) == 1 {
+		return 0, net.ErrClosed
+	}
+	n, err = tr.conn.Read(p)
+	if n > 0 {
+		atomic.AddInt64(tr.read, int64(n))
+		sessionReadBytes.Add(float64(n))
+	}
+	return
 }
 
 type reader struct {
@@ -106,13 +161,10 @@ type reader struct {
 	size int
 }
 
-func newReader(conn net.Conn, timeout time.Duration) *reader {
+func newReader(tr *timeoutReader) *reader {
 	return &reader{
-		conn: conn,
-		bufr: bufio.NewReader(&timeoutReader{
-			conn:    conn,
-			timeout: timeout,
-		}),
+		conn: tr.conn,
+		bufr: bufio.NewReader(tr),
 		size: -1,
 	}
 }
@@ -186,7 +238,9 @@ func (b *reader) discardAll() error {
 type Option func(*option)
 
 type option struct {
-	timeout time.Duration
+	timeout   time.Duration
+	heartbeat time.Duration
+	maxMissed int
 }
 
 func defaultOption() option {
@@ -200,14 +254,40 @@ func WithTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithHeartbeat enables heartbeat of session. Every interval, a ping is sent
+// to the remote, and the remote replies a pong, which is used to measure RTT.
+// A beat is missed if nothing received in an interval, and the session is
+// closed with ErrHeartbeatTimeout after maxMissed (at least 1) beats missed
+// in a row.
+//
+// Pings of textproto sessions are "+PING\r\n" commands, which should be
+// replied with "+PONG\r\n" by clients. PING commands of clients are replied
+// with "+PONG", too. So PING and PONG commands are consumed by the session
+// and never passed to CommandHandler.OnCommand if heartbeat enabled.
+//
+// Heartbeat messages are not counted by metrics of sent and received messages.
+func WithHeartbeat(interval time.Duration, maxMissed int) Option {
+	return func(opt *option) {
+		opt.heartbeat = interval
+		opt.maxMissed = maxMissed
+	}
+}
+
 // SessionEventHandler handles session events
 type SessionEventHandler interface {
 	OnOpen()                                // ready to read/write
-	OnClose(err error)                      // session closed, err maybe nil
+	OnClose(err error)                      // session closed, err is the reason, maybe nil
 	OnHandshake(proto.ContentType) error    // session handshaked
 	OnMessage(proto.Type, proto.Body) error // received a message
 }
 
+// ContextMessageHandler is an optional interface which could be implemented by
+// SessionEventHandler to receive messages with context. The context carries
+// the trace context sent by WriteContext of remote session.
+type ContextMessageHandler interface {
+	OnMessageContext(context.Context, proto.Type, proto.Body) error
+}
+
 // Command represents textproto command
 type Command interface {
 	Name() string     // Name of command
@@ -228,6 +308,10 @@ type Session struct {
 	handler        SessionEventHandler
 	command        *resp.Command
 	commandHandler CommandHandler
+	contextHandler ContextMessageHandler
+
+	// trace context of the next message, accessed in read loop only
+	trace tracing.SpanContext
 
 	// Handshake state
 	handshaked  bool
@@ -237,6 +321,17 @@ type Session struct {
 	closed   int32
 	wrunning int32
 
+	// heartbeat state
+	heartbeat time.Duration
+	maxMissed int
+	created   time.Time // base of timestamps of pings
+	pingKind  int32     // pingBinary or pingText after handshaked
+	pinged    int64     // timestamp of the unanswered ping of textproto session
+	read      int64     // number of bytes read
+	rtt       int64     // the last RTT in nanoseconds
+
+	wmu sync.Mutex // serializes Write calls with heartbeats
+
 	errMu sync.RWMutex
 	err   error
 
@@ -253,14 +348,28 @@ func NewSession(conn net.Conn, handler SessionEventHandler, options ...Option) *
 		options[i](&opt)
 	}
 	s := &Session{
-		reader:  newReader(conn, opt.timeout),
-		writer:  bufio.NewWriter(conn),
-		handler: handler,
-		pipe:    pagebuf.NewPageBuffer(),
-	}
+		writer:    bufio.NewWriter(conn),
+		handler:   handler,
+		pipe:      pagebuf.NewPageBuffer(),
+		heartbeat: opt.heartbeat,
+		maxMissed: opt.maxMissed,
+		created:   time.Now(),
+	}
+	if s.maxMissed < 1 {
+		s.maxMissed = 1
+	}
+	s.reader = newReader(&timeoutReader{
+		conn:    conn,
+		timeout: opt.timeout,
+		closed:  &s.closed,
+		read:    &s.read,
+	})
 	if commandHandler, ok := handler.(CommandHandler); ok {
 		s.commandHandler = commandHandler
 	}
+	if contextHandler, ok := handler.(ContextMessageHandler); ok {
+		s.contextHandler = contextHandler
+	}
 	s.cond = sync.NewCond(&s.mutex)
 	s.bufw = make([]byte, s.pipe.PageSize())
 	return s
@@ -276,12 +385,30 @@ func (s *Session) ContentType() proto.ContentType {
 	return s.contentType
 }
 
-// Write implements io.Writer Write method, this IS NOT thread-safe.
+// RTT returns the last round-trip time measured by heartbeat, 0 returned if
+// heartbeat disabled or no pong received.
+func (s *Session) RTT() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.rtt))
+}
+
+// Write implements io.Writer Write method, this IS NOT thread-safe, but p is
+// never interleaved with heartbeats.
 func (s *Session) Write(p []byte) (n int, err error) {
+	return s.write(p, true)
+}
+
+// write writes p, it's counted as a sent message if message is true, or
+// else p is a control message like heartbeats and trace contexts.
+func (s *Session) write(p []byte, message bool) (n int, err error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
 	if s.IsClosed() {
 		err = net.ErrClosed
 		return
 	}
+	if message {
+		sessionSentMessages.Inc()
+	}
 	var (
 		size         = len(p)
 		maxWriteSize = s.pipe.PageSize() << 2
@@ -308,6 +435,23 @@ func (s *Session) Write(p []byte) (n int, err error) {
 	return
 }
 
+// WriteContext writes p like Write, and the trace context in ctx is written
+// as a proto.TraceType message before p if the session is not textproto.
+// So p MUST contain exactly one message to attach the trace context to.
+func (s *Session) WriteContext(ctx context.Context, p []byte) (n int, err error) {
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() && !proto.IsTextproto(s.contentType) {
+		traceparent := sc.Traceparent()
+		var buf [2*binary.MaxVarintLen64 + 64]byte
+		off := proto.EncodeType(buf[:], proto.TraceType)
+		off += proto.EncodeSize(buf[off:], len(traceparent))
+		off += copy(buf[off:], traceparent)
+		if _, err = s.write(buf[:off], false); err != nil {
+			return
+		}
+	}
+	return s.Write(p)
+}
+
 // Serve runs the read/write loops, it will block until the session closed
 func (s *Session) Serve() bool {
 	if !atomic.CompareAndSwapInt32(&s.started, 0, 1) {
@@ -329,8 +473,17 @@ func (s *Session) Serve() bool {
 
 	s.handler.OnOpen()
 
+	var quit chan struct{}
+	if s.heartbeat > 0 {
+		quit = make(chan struct{})
+		go s.beatLoop(quit)
+	}
+
 	// Blcoking
 	closeWg.Wait()
+	if quit != nil {
+		close(quit)
+	}
 
 	s.errMu.RLock()
 	err := s.err
@@ -361,18 +514,99 @@ func (s *Session) IsClosed() bool {
 	return atomic.LoadInt32(&s.closed) == 1
 }
 
+// setClosed marks the session closed, err of the first call is the reason
+// reported to OnClose.
 func (s *Session) setClosed(err error) {
-	if err != nil {
-		s.errMu.Lock()
+	s.errMu.Lock()
+	if atomic.LoadInt32(&s.closed) == 0 {
 		s.err = err
-		s.errMu.Unlock()
+		atomic.StoreInt32(&s.closed, 1)
 	}
-	atomic.StoreInt32(&s.closed, 1)
+	s.errMu.Unlock()
 }
 
-// Close closes the session
+// Close closes the session with a reason, blocking reads are interrupted
 func (s *Session) Close(err error) {
 	s.setClosed(err)
+	s.reader.conn.SetReadDeadline(time.Now())
+	s.cond.Signal()
+}
+
+// beatLoop sends pings and checks missed beats every heartbeat interval
+func (s *Session) beatLoop(quit <-chan struct{}) {
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+	var (
+		read   = atomic.LoadInt64(&s.read)
+		missed int
+	)
+	for {
+		select {
+		case <-ticker.C:
+			if n := atomic.LoadInt64(&s.read); n != read {
+				read, missed = n, 0
+			} else if missed++; missed >= s.maxMissed {
+				log.Debug().
+					String("remote", s.reader.conn.RemoteAddr().String()).
+					Int("missed", missed).
+					Print("session heartbeat timeout")
+				s.Close(ErrHeartbeatTimeout)
+				return
+			}
+			switch atomic.LoadInt32(&s.pingKind) {
+			case pingBinary:
+				s.writeHeartbeat(heartbeatPing, int64(time.Since(s.created)))
+			case pingText:
+				// keep the earliest unanswered ping, pongs are replied in order
+				if atomic.CompareAndSwapInt64(&s.pinged, 0, int64(time.Since(s.created))) {
+					s.write([]byte("+PING\r\n"), false)
+				}
+			}
+		case <-quit:
+			return
+		}
+	}
+}
+
+func (s *Session) writeHeartbeat(kind byte, timestamp int64) error {
+	var buf [2*binary.MaxVarintLen64 + heartbeatSize]byte
+	off := proto.EncodeType(buf[:], proto.HeartbeatType)
+	off += proto.EncodeSize(buf[off:], heartbeatSize)
+	buf[off] = kind
+	binary.BigEndian.PutUint64(buf[off+1:], uint64(timestamp))
+	off += heartbeatSize
+	_, err := s.write(buf[:off], false)
+	return err
+}
+
+// readHeartbeat reads body of proto.HeartbeatType message, pings are replied
+// with pongs, and RTT is updated by pongs.
+func (s *Session) readHeartbeat() error {
+	if s.reader.size != heartbeatSize {
+		return s.reader.discardAll()
+	}
+	var buf [heartbeatSize]byte
+	if _, err := io.ReadFull(s.reader, buf[:]); err != nil {
+		return err
+	}
+	timestamp := int64(binary.BigEndian.Uint64(buf[1:]))
+	switch buf[0] {
+	case heartbeatPing:
+		return s.writeHeartbeat(heartbeatPong, timestamp)
+	case heartbeatPong:
+		s.updateRTT(timestamp)
+	}
+	return nil
+}
+
+// updateRTT updates RTT by timestamp of the ping replied, 0 means no ping
+func (s *Session) updateRTT(timestamp int64) {
+	if timestamp <= 0 {
+		return
+	}
+	if rtt := int64(time.Since(s.created)) - timestamp; rtt >= 0 {
+		atomic.StoreInt64(&s.rtt, rtt)
+	}
 }
 
 func (s *Session) readLoop(readyWg, closeWg *sync.WaitGroup) {
@@ -432,7 +666,10 @@ func (s *Session) flush() {
 }
 
 func (s *Session) underlyingWrite(p []byte) error {
-	_, err := s.writer.Write(p)
+	n, err := s.writer.Write(p)
+	if n > 0 {
+		sessionWrittenBytes.Add(float64(n))
+	}
 	if err != nil {
 		s.setClosed(err)
 	}
@@ -467,6 +704,17 @@ func (s *Session) underlyingRead() error {
 			_, err := s.Write([]byte("-don't hello again\r\n"))
 			return err
 		}
+		if s.heartbeat > 0 {
+			if s.command.Is(ping) {
+				_, err := s.write([]byte("+PONG\r\n"), false)
+				return err
+			}
+			if s.command.Is(pong) {
+				s.updateRTT(atomic.SwapInt64(&s.pinged, 0))
+				return nil
+			}
+		}
+		sessionReceivedMessages.Inc()
 		return s.commandHandler.OnCommand(s.command)
 	}
 
@@ -480,13 +728,45 @@ func (s *Session) underlyingRead() error {
 		return err
 	}
 	s.reader.size = size
-	if err := s.handler.OnMessage(typ, s.reader); err != nil {
+	switch typ {
+	case proto.TraceType:
+		return s.readTrace()
+	case proto.HeartbeatType:
+		return s.readHeartbeat()
+	}
+	sessionReceivedMessages.Inc()
+	if s.contextHandler != nil {
+		ctx := context.Background()
+		if s.trace.IsValid() {
+			ctx = tracing.ContextWithRemote(ctx, s.trace)
+		}
+		err = s.contextHandler.OnMessageContext(ctx, typ, s.reader)
+	} else {
+		err = s.handler.OnMessage(typ, s.reader)
+	}
+	s.trace = tracing.SpanContext{}
+	if err != nil {
 		return err
 	}
 	// discard unread bytes
 	return s.reader.discardAll()
 }
 
+// readTrace reads body of proto.TraceType message as the trace context of
+// the next message, malformed trace context is ignored.
+func (s *Session) readTrace() error {
+	var buf [64]byte
+	if s.reader.size > len(buf) {
+		return s.reader.discardAll()
+	}
+	n, err := io.ReadFull(s.reader, buf[:s.reader.size])
+	if err != nil {
+		return err
+	}
+	s.trace, _ = tracing.ParseTraceparent(string(buf[:n]))
+	return nil
+}
+
 func (s *Session) readCommand() error {
 	if s.command == nil {
 		s.command = resp.NewCommand()
@@ -568,6 +848,11 @@ func (s *Session) handshake(typ proto.Type) error {
 	}
 	s.handshaked = true
 	s.contentType = contentType
+	if !proto.IsTextproto(contentType) {
+		atomic.StoreInt32(&s.pingKind, pingBinary)
+	} else if s.commandHandler != nil {
+		atomic.StoreInt32(&s.pingKind, pingText)
+	}
 	var buf = make([]byte, 0, 16)
 	buf = append(buf, resp.StringType.Byte())
 	buf = append(buf, hello...)
@@ -0,0 +1,173 @@
+package netutil_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/net/netutil"
+	"github.com/gopherd/doge/proto"
+)
+
+type testHandler struct {
+	closed chan error
+}
+
+func (h *testHandler) OnOpen()                                {}
+func (h *testHandler) OnClose(err error)                      { h.closed <- err }
+func (h *testHandler) OnHandshake(proto.ContentType) error    { return nil }
+func (h *testHandler) OnMessage(proto.Type, proto.Body) error { return nil }
+func (h *testHandler) Commands() []string                     { return nil }
+func (h *testHandler) OnCommand(cmd netutil.Command) error    { return nil }
+
+// serve accepts a connection served by a new session with heartbeat, and
+// returns the session and the client connection
+func serve(t *testing.T, contentType proto.ContentType) (*netutil.Session, *testHandler, net.Conn, *bufio.Reader) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &testHandler{closed: make(chan error, 1)}
+	s := netutil.NewSession(conn, h, netutil.WithHeartbeat(20*time.Millisecond, 3))
+	go s.Serve()
+
+	r := bufio.NewReader(client)
+	hello := "+hello " + string(rune('0'+contentType)) + "\r\n"
+	if _, err := io.WriteString(client, hello); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := r.ReadString('\n'); err != nil || line != hello {
+		t.Fatalf("handshake: got %q, error: %v", line, err)
+	}
+	return s, h, client, r
+}
+
+func waitClosed(t *testing.T, h *testHandler) error {
+	select {
+	case err := <-h.closed:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("session not closed")
+		return nil
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	s, h, client, r := serve(t, proto.ContentTypeProtobuf)
+	for pongs := 0; pongs < 3; pongs++ {
+		typ, err := proto.ReadType(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := proto.ReadSize(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r, body); err != nil {
+			t.Fatal(err)
+		}
+		if typ != proto.HeartbeatType || size != 9 || body[0] != 0 {
+			t.Fatalf("unexpected message: type %d, body %v", typ, body)
+		}
+		time.Sleep(time.Millisecond)
+		body[0] = 1 // pong
+		var buf [2*binary.MaxVarintLen64 + 9]byte
+		off := proto.EncodeType(buf[:], proto.HeartbeatType)
+		off += proto.EncodeSize(buf[off:], size)
+		off += copy(buf[off:], body)
+		if _, err := client.Write(buf[:off]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the session is closed after beats missed
+	go io.Copy(io.Discard, r)
+	if err := waitClosed(t, h); !errors.Is(err, netutil.ErrHeartbeatTimeout) {
+		t.Fatalf("want heartbeat timeout, got %v", err)
+	}
+	if rtt := s.RTT(); rtt < time.Millisecond {
+		t.Errorf("unexpected rtt %v", rtt)
+	}
+}
+
+// readLine reads a line from r, pings of the session are replied with pongs
+// after delay if pong is true, otherwise skipped.
+func readLine(client net.Conn, r *bufio.Reader, pong bool, delay time.Duration) (string, error) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil || line != "+PING\r\n" {
+			return line, err
+		}
+		if pong {
+			time.Sleep(delay)
+			if _, err := io.WriteString(client, "+PONG\r\n"); err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+func TestHeartbeatText(t *testing.T) {
+	_, h, client, r := serve(t, proto.ContentTypeText)
+	for i := 0; i < 5; i++ {
+		if _, err := io.WriteString(client, "+PING\r\n"); err != nil {
+			t.Fatal(err)
+		}
+		if line, err := readLine(client, r, false, 0); err != nil || line != "+PONG\r\n" {
+			t.Fatalf("ping: got %q, error: %v", line, err)
+		}
+		time.Sleep(30 * time.Millisecond)
+	}
+	select {
+	case err := <-h.closed:
+		t.Fatalf("session closed while pinging: %v", err)
+	default:
+	}
+	if err := waitClosed(t, h); !errors.Is(err, netutil.ErrHeartbeatTimeout) {
+		t.Fatalf("want heartbeat timeout, got %v", err)
+	}
+	if line, _ := readLine(client, r, false, 0); !strings.Contains(line, netutil.ErrHeartbeatTimeout.Error()) {
+		t.Errorf("unexpected close message %q", line)
+	}
+}
+
+func TestHeartbeatTextPing(t *testing.T) {
+	s, h, client, r := serve(t, proto.ContentTypeText)
+	done := make(chan string, 1)
+	go func() {
+		// the session is kept alive by pongs only
+		line, _ := readLine(client, r, true, time.Millisecond)
+		done <- line
+	}()
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case err := <-h.closed:
+		t.Fatalf("session closed while ponging: %v", err)
+	default:
+	}
+	if rtt := s.RTT(); rtt < time.Millisecond {
+		t.Errorf("unexpected rtt %v", rtt)
+	}
+	s.Close(nil)
+	if err := waitClosed(t, h); err != nil {
+		t.Fatalf("want closed without error, got %v", err)
+	}
+	if line := <-done; line != "-connection closed\r\n" {
+		t.Errorf("unexpected close message %q", line)
+	}
+}
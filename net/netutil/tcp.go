@@ -144,7 +144,6 @@ func (server *TCPServer) Serve(listener net.Listener) error {
 		}
 		go server.handler(ip, conn)
 	}
-	return nil
 }
 
 // Shutdown shutdowns the tcp server
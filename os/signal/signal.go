@@ -11,7 +11,7 @@ type SignalHandler func(os.Signal) bool
 var (
 	mu       sync.Mutex
 	handlers = map[os.Signal][]SignalHandler{}
-	sigChan  = make(chan os.Signal)
+	sigChan  = make(chan os.Signal, 1)
 )
 
 func Register(sig os.Signal, handler SignalHandler) {
@@ -20,6 +20,24 @@ const (
 	MaxType = 1 << 31
 )
 
+// Reserved message types used by the framework, Register panics for them.
+const (
+	// TraceType is the type of messages carrying W3C traceparent of the next
+	// message in the same session
+	TraceType Type = MaxType - iota
+	// HeartbeatType is the type of heartbeat messages, the body is a ping or
+	// pong, see netutil.WithHeartbeat
+	HeartbeatType
+)
+
+// NumReservedTypes is the number of reserved types in range (MaxType-NumReservedTypes, MaxType]
+const NumReservedTypes = 16
+
+// IsReserved reports whether the type is reserved by the framework
+func IsReserved(typ Type) bool {
+	return typ > MaxType-NumReservedTypes && typ <= MaxType
+}
+
 var (
 	ErrVarintOverflow         = errors.New("proto: varint overflow")
 	ErrSizeOverflow           = errors.New("proto: size overflow")
@@ -136,6 +154,9 @@ func Register(mod string, typ Type, creator func() Message) {
 	if typ > MaxType {
 		panic(fmt.Sprintf("proto: Register type %d out of range [0, %d]", typ, MaxType))
 	}
+	if IsReserved(typ) {
+		panic(fmt.Sprintf("proto: Register reserved type %d", typ))
+	}
 	if creator == nil {
 		panic(fmt.Sprintf("proto: Register creator is nil for type %d", typ))
 	}
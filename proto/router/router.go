@@ -132,10 +132,30 @@ func (cache *Cache) Shutdown() {
 }
 
 func (cache *Cache) run() {
+	// apply changes immediately if the discovery could push changes
+	var events <-chan discovery.Event
+	if w, ok := cache.discovery.(discovery.Watcher); ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if ch, err := w.Watch(ctx, prefix); err == nil {
+			events = ch
+		}
+	}
 	ticker := time.NewTicker(time.Millisecond * 100)
 	defer ticker.Stop()
 	for {
 		select {
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			switch e.Type {
+			case discovery.Put:
+				cache.Add(e.ID, e.Content)
+			case discovery.Delete:
+				cache.Remove(e.ID)
+			}
 		case <-ticker.C:
 			for cache.tryReloadFirst() {
 			}
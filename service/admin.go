@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gopherd/log"
+
+	"github.com/gopherd/doge/build"
+	"github.com/gopherd/doge/config"
+	"github.com/gopherd/doge/metrics"
+	"github.com/gopherd/doge/net/httputil"
+)
+
+// ModuleInfo represents a module listed by the admin server
+type ModuleInfo struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// startAdmin starts the admin http server which serves:
+//
+//	GET  /healthz  200 if the service is not closed
+//	GET  /readyz   200 if the service is running
+//	GET  /version  build version
+//	GET  /config   current config as JSON, secrets masked
+//	GET  /config/origins  layers of config values by key paths
+//	GET  /modules  list of modules
+//	GET  /reload   report of the last reloading
+//	POST /reload   reload config
+//	GET  /metrics  metrics in Prometheus text format
+func (app *BasicService) startAdmin(cfg config.AdminConfig) error {
+	httpd := httputil.NewHTTPServer(httputil.Config{Address: cfg.Address})
+	httpd.HandleFunc("/healthz", app.handleHealthz)
+	httpd.HandleFunc("/readyz", app.handleReadyz)
+	httpd.HandleFunc("/version", app.handleVersion)
+	httpd.HandleFunc("/config", app.handleConfig)
+	httpd.HandleFunc("/config/origins", app.handleConfigOrigins)
+	httpd.HandleFunc("/modules", app.handleModules)
+	httpd.HandleFunc("/reload", app.handleReload)
+	httpd.Handle("/metrics", metrics.Handler())
+	l, err := httpd.Listen()
+	if err != nil {
+		return err
+	}
+	app.admin = httpd
+	log.Info().String("address", l.Addr().String()).Print("admin server listening")
+	go func() {
+		if err := httpd.Serve(l); err != nil && err != http.ErrServerClosed {
+			log.Warn().Error("error", err).Print("admin server error")
+		}
+	}()
+	return nil
+}
+
+func (app *BasicService) shutdownAdmin() {
+	if app.admin == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := app.admin.Shutdown(ctx); err != nil {
+		log.Warn().Error("error", err).Print("shutdown admin server error")
+	}
+}
+
+// Admin returns the admin http server, nil returned if it's disabled.
+// More handlers could be added to the server.
+func (app *BasicService) Admin() *httputil.HTTPServer {
+	return app.admin
+}
+
+func (app *BasicService) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	state := app.State()
+	if state == Closed {
+		httputil.TextResponse(w, state.String(), httputil.WithStatus(http.StatusServiceUnavailable))
+		return
+	}
+	httputil.TextResponse(w, state.String())
+}
+
+func (app *BasicService) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	state := app.State()
+	if state != Running {
+		httputil.TextResponse(w, state.String(), httputil.WithStatus(http.StatusServiceUnavailable))
+		return
+	}
+	httputil.TextResponse(w, state.String())
+}
+
+func (app *BasicService) handleVersion(w http.ResponseWriter, r *http.Request) {
+	httputil.TextResponse(w, build.Version())
+}
+
+func (app *BasicService) handleConfig(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.Mask(app.Config())
+	if err != nil {
+		httputil.TextResponse(w, err.Error(), httputil.WithStatus(http.StatusInternalServerError))
+		return
+	}
+	httputil.JSONResponse(w, cfg)
+}
+
+func (app *BasicService) handleConfigOrigins(w http.ResponseWriter, r *http.Request) {
+	httputil.JSONResponse(w, config.OriginsOf(app.Config()))
+}
+
+func (app *BasicService) handleModules(w http.ResponseWriter, r *http.Request) {
+	modules := make([]ModuleInfo, 0, app.modules.Len())
+	for i, n := 0, app.modules.Len(); i < n; i++ {
+		var (
+			info = ModuleInfo{Name: app.modules.GetLifecycle(i).Name()}
+			t    reflect.Type
+		)
+		if mod := app.modules.Get(i); mod != nil {
+			t = reflect.TypeOf(mod)
+		} else {
+			t = reflect.TypeOf(app.modules.GetLifecycle(i))
+		}
+		info.Type = t.String()
+		modules = append(modules, info)
+	}
+	httputil.JSONResponse(w, modules)
+}
+
+func (app *BasicService) handleReload(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		httputil.JSONResponse(w, app.LastReload())
+		return
+	case http.MethodPost:
+	default:
+		w.Header().Set(httputil.HeaderAllow, http.MethodGet+", "+http.MethodPost)
+		httputil.TextResponse(w, "method not allowed", httputil.WithStatus(http.StatusMethodNotAllowed))
+		return
+	}
+	type result struct {
+		report *ReloadReport
+		err    error
+	}
+	var (
+		res    result
+		done   = make(chan result, 1)
+		reload = func() {
+			report, err := app.reload(true)
+			done <- result{report, err}
+		}
+	)
+	// reload in the frame loop as the periodic reloading does if it's running
+	if app.loop != nil && app.loop.post(reload) {
+		select {
+		case res = <-done:
+		case <-r.Context().Done():
+			return
+		}
+	} else {
+		reload()
+		res = <-done
+	}
+	if res.err != nil {
+		log.Warn().Error("error", res.err).Print("reload config error")
+		if res.report == nil {
+			httputil.TextResponse(w, res.err.Error(), httputil.WithStatus(http.StatusInternalServerError))
+		} else {
+			httputil.JSONResponse(w, res.report, httputil.WithStatus(http.StatusInternalServerError))
+		}
+		return
+	}
+	log.Info().Strings("changed", res.report.Changed).Print("config reloaded by admin")
+	httputil.JSONResponse(w, res.report)
+}
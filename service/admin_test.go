@@ -0,0 +1,146 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/gopherd/doge/config"
+	"github.com/gopherd/doge/service/module"
+)
+
+type testConfig struct {
+	config.BasicConfig
+	Value int `json:"value"`
+}
+
+func (c *testConfig) Default() config.Configurator {
+	return new(testConfig)
+}
+
+func (c *testConfig) Validate() error {
+	if c.Value < 0 {
+		return errors.New("negative value")
+	}
+	return c.BasicConfig.Validate()
+}
+
+type testService struct {
+	*BasicService
+	cfg *testConfig
+}
+
+func (s *testService) RewriteConfig(ptr unsafe.Pointer) {
+	s.cfg = (*testConfig)(ptr)
+}
+
+func newTestService(t *testing.T) *testService {
+	source := filepath.Join(t.TempDir(), "test.conf")
+	cfg := new(testConfig)
+	cfg.SetSource(source)
+	s := &testService{cfg: cfg}
+	s.BasicService = NewBasicService(s, cfg)
+	return s
+}
+
+func serve(handler http.HandlerFunc, method string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(method, "/", nil))
+	return w
+}
+
+func TestAdminState(t *testing.T) {
+	s := newTestService(t)
+	if w := serve(s.handleHealthz, http.MethodGet); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("healthz of closed service: want 503, got %d", w.Code)
+	}
+	s.state.Store(int32(Running))
+	if w := serve(s.handleReadyz, http.MethodGet); w.Code != http.StatusOK {
+		t.Errorf("readyz of running service: want 200, got %d", w.Code)
+	}
+	s.state.Store(int32(Stopping))
+	if w := serve(s.handleHealthz, http.MethodGet); w.Code != http.StatusOK {
+		t.Errorf("healthz of stopping service: want 200, got %d", w.Code)
+	}
+	if w := serve(s.handleReadyz, http.MethodGet); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("readyz of stopping service: want 503, got %d", w.Code)
+	}
+}
+
+func TestAdminModules(t *testing.T) {
+	s := newTestService(t)
+	s.AddModule(module.NewBasicModule("foo"))
+	w := serve(s.handleModules, http.MethodGet)
+	var modules []ModuleInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &modules); err != nil {
+		t.Fatalf("unmarshal modules error: %v", err)
+	}
+	if len(modules) != 1 || modules[0].Name != "foo" || modules[0].Type != "*module.BasicModule" {
+		t.Errorf("unexpected modules: %+v", modules)
+	}
+}
+
+func TestAdminReload(t *testing.T) {
+	s := newTestService(t)
+	if w := serve(s.handleReload, http.MethodPut); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT reload: want 405, got %d", w.Code)
+	}
+	if err := os.WriteFile(s.cfg.GetSource(), []byte(`{value: 42}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := serve(s.handleReload, http.MethodPost)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST reload: want 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report ReloadReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("unmarshal report error: %v", err)
+	}
+	if s.cfg.Value != 42 || !report.Forced || len(report.Changed) != 1 || report.Changed[0] != "value" {
+		t.Errorf("config not reloaded: %+v, report: %+v", s.cfg, report)
+	}
+
+	// not reloaded if content not changed
+	if report, err := s.reload(false); report != nil || err != nil {
+		t.Errorf("unexpected reloading: %+v, %v", report, err)
+	}
+	// the old config kept if failed
+	for _, content := range []string{`{value: }`, `{value: -1}`} {
+		if err := os.WriteFile(s.cfg.GetSource(), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if report, err := s.reload(false); err == nil || report == nil || report.Error == "" {
+			t.Errorf("%s: want reloading error, got %+v, %v", content, report, err)
+		}
+		if s.cfg.Value != 42 || s.LastReload().Error == "" {
+			t.Errorf("%s: old config should be kept: %+v", content, s.cfg)
+		}
+	}
+	w = serve(s.handleReload, http.MethodGet)
+	if !strings.Contains(w.Body.String(), "negative value") {
+		t.Errorf("validation error not reported: %s", w.Body.String())
+	}
+	w = serve(s.handleConfig, http.MethodGet)
+	if !strings.Contains(w.Body.String(), `"value":42`) {
+		t.Errorf("unexpected config: %s", w.Body.String())
+	}
+
+	// reloaded directly if the loop is not running
+	if err := os.WriteFile(s.cfg.GetSource(), []byte(`{value: 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.loop = NewLoop(func(time.Time, time.Duration) {})
+	for i, step := range []func(){func() {}, s.loop.Start, s.loop.Shutdown} {
+		step()
+		if w := serve(s.handleReload, http.MethodPost); w.Code != http.StatusOK || s.cfg.Value != 1 {
+			t.Errorf("step %d: POST reload: want 200, got %d: %s", i, w.Code, w.Body.String())
+		}
+	}
+}
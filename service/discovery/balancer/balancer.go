@@ -0,0 +1,203 @@
+// Package balancer implements client-side load balancing over discovered services.
+//
+// Balancer keeps a cached view of discovery.ResolveAll(name), parses each content
+// as service.DiscoveryContent, and skips instances which are not Running, whose
+// Updated stamp is stale, or whose health is Unhealthy. Degraded instances are
+// still picked.
+//
+//	b := balancer.New(d, "gate", balancer.WithStrategy(balancer.RoundRobin()))
+//	if err := b.Init(); err != nil {
+//		return err
+//	}
+//	b.Start()
+//	defer b.Shutdown()
+//	instance, err := b.Pick("")
+package balancer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gopherd/doge/service"
+	"github.com/gopherd/doge/service/discovery"
+	"github.com/gopherd/doge/service/module"
+)
+
+// ErrNoInstance represents an error in case of no available instance
+var ErrNoInstance = errors.New("balancer: no available instance")
+
+// Instance represents a discovered service instance
+type Instance struct {
+	ID      string                   // id of service
+	Raw     string                   // raw discovery content
+	Content service.DiscoveryContent // parsed discovery content
+}
+
+// Option represents options of New
+type Option func(*option)
+
+type option struct {
+	strategy   Strategy
+	interval   time.Duration
+	staleAfter time.Duration
+}
+
+func defaultOption() option {
+	return option{
+		interval:   time.Second,
+		staleAfter: 10 * time.Second,
+	}
+}
+
+// WithStrategy specify the picking strategy, RoundRobin used by default
+func WithStrategy(strategy Strategy) Option {
+	return func(opt *option) {
+		opt.strategy = strategy
+	}
+}
+
+// WithInterval specify the interval of refreshing instances, 1s by default
+func WithInterval(interval time.Duration) Option {
+	return func(opt *option) {
+		opt.interval = interval
+	}
+}
+
+// WithStaleAfter specify the duration after which an instance with no
+// Updated stamp changed is regarded as stale, 10s by default. Instances
+// never be stale if staleAfter <= 0.
+func WithStaleAfter(staleAfter time.Duration) Option {
+	return func(opt *option) {
+		opt.staleAfter = staleAfter
+	}
+}
+
+// Balancer picks instances of a named service by the strategy
+type Balancer struct {
+	discovery discovery.Discovery
+	name      string
+	opt       option
+
+	mu        sync.Mutex
+	instances []Instance
+
+	quit, wait chan struct{}
+	running    int32
+}
+
+// New creates a Balancer for service name
+func New(discovery discovery.Discovery, name string, options ...Option) *Balancer {
+	var opt = defaultOption()
+	for i := range options {
+		options[i](&opt)
+	}
+	if opt.strategy == nil {
+		opt.strategy = RoundRobin()
+	}
+	if opt.interval <= 0 {
+		opt.interval = time.Second
+	}
+	return &Balancer{
+		discovery: discovery,
+		name:      name,
+		opt:       opt,
+		quit:      make(chan struct{}),
+		wait:      make(chan struct{}),
+	}
+}
+
+// Init loads instances at first time
+func (b *Balancer) Init() error {
+	return b.Refresh(context.Background())
+}
+
+// Start starts refreshing instances every interval in background
+func (b *Balancer) Start() {
+	if atomic.CompareAndSwapInt32(&b.running, 0, 1) {
+		go b.run()
+	}
+}
+
+// Shutdown stops refreshing instances
+func (b *Balancer) Shutdown() {
+	if atomic.CompareAndSwapInt32(&b.running, 1, 0) {
+		close(b.quit)
+		<-b.wait
+	}
+}
+
+func (b *Balancer) run() {
+	ticker := time.NewTicker(b.opt.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.Refresh(context.Background())
+		case <-b.quit:
+			close(b.wait)
+			return
+		}
+	}
+}
+
+// Refresh reloads instances from discovery, the cached view is kept if failed
+func (b *Balancer) Refresh(ctx context.Context) error {
+	services, err := b.discovery.ResolveAll(ctx, b.name)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano() / 1e6
+	instances := make([]Instance, 0, len(services))
+	for id, raw := range services {
+		var instance = Instance{ID: id, Raw: raw}
+		if err := json.Unmarshal([]byte(raw), &instance.Content); err != nil {
+			continue
+		}
+		if instance.Content.State.State != service.Running {
+			continue
+		}
+		if instance.Content.State.Health == module.Unhealthy {
+			continue
+		}
+		if b.opt.staleAfter > 0 && instance.Content.State.Updated+int64(b.opt.staleAfter/time.Millisecond) < now {
+			continue
+		}
+		instances = append(instances, instance)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ID < instances[j].ID
+	})
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.instances = instances
+	b.opt.strategy.Update(instances)
+	return nil
+}
+
+// Instances returns all available instances sorted by id
+func (b *Balancer) Instances() []Instance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Instance(nil), b.instances...)
+}
+
+// Pick picks an available instance, the key is used by strategies like
+// ConsistentHash and ignored by others. ErrNoInstance returned if no
+// instance available.
+func (b *Balancer) Pick(key string) (Instance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.instances) == 0 {
+		return Instance{}, ErrNoInstance
+	}
+	instance, ok := b.opt.strategy.Pick(key)
+	if !ok {
+		return Instance{}, ErrNoInstance
+	}
+	return instance, nil
+}
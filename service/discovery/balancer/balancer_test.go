@@ -0,0 +1,118 @@
+package balancer_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/service"
+	"github.com/gopherd/doge/service/discovery/balancer"
+	"github.com/gopherd/doge/service/discovery/memory"
+	"github.com/gopherd/doge/service/module"
+)
+
+func register(t *testing.T, d *memory.Discovery, id string, state service.State, updated time.Time, health module.Health) {
+	t.Helper()
+	var content service.DiscoveryContent
+	content.State.State = state
+	content.State.Health = health
+	content.State.Updated = updated.UnixNano() / 1e6
+	data, err := json.Marshal(content)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if err := d.Register(context.Background(), "foo", id, string(data), false, 0); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+}
+
+func newDiscovery(t *testing.T) *memory.Discovery {
+	d := memory.New()
+	now := time.Now()
+	register(t, d, "1", service.Running, now, module.Healthy)
+	register(t, d, "2", service.Running, now, module.Healthy)
+	register(t, d, "3", service.Running, now, module.Healthy)
+	register(t, d, "4", service.Stopping, now, module.Healthy)
+	register(t, d, "5", service.Running, now.Add(-time.Minute), module.Healthy)
+	register(t, d, "6", service.Running, now, module.Unhealthy)
+	return d
+}
+
+func TestRoundRobin(t *testing.T) {
+	b := balancer.New(newDiscovery(t), "foo")
+	if err := b.Init(); err != nil {
+		t.Fatalf("init error: %v", err)
+	}
+	if n := len(b.Instances()); n != 3 {
+		t.Fatalf("want 3 instances, but got %d", n)
+	}
+	for i, want := range []string{"1", "2", "3", "1", "2"} {
+		instance, err := b.Pick("")
+		if err != nil || instance.ID != want {
+			t.Fatalf("#%d: want %s, but got %s, error %v", i, want, instance.ID, err)
+		}
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	weights := map[string]int{"1": 5, "2": 1, "3": 0}
+	b := balancer.New(newDiscovery(t), "foo", balancer.WithStrategy(balancer.Weighted(func(instance balancer.Instance) int {
+		return weights[instance.ID]
+	})))
+	if err := b.Init(); err != nil {
+		t.Fatalf("init error: %v", err)
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 60; i++ {
+		instance, err := b.Pick("")
+		if err != nil {
+			t.Fatalf("pick error: %v", err)
+		}
+		counts[instance.ID]++
+	}
+	if counts["1"] != 50 || counts["2"] != 10 || counts["3"] != 0 {
+		t.Fatalf("unexpected counts: %v", counts)
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	d := newDiscovery(t)
+	b := balancer.New(d, "foo", balancer.WithStrategy(balancer.ConsistentHash(32)))
+	if err := b.Init(); err != nil {
+		t.Fatalf("init error: %v", err)
+	}
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	picked := make(map[string]string)
+	for _, key := range keys {
+		instance, err := b.Pick(key)
+		if err != nil {
+			t.Fatalf("pick error: %v", err)
+		}
+		picked[key] = instance.ID
+		if again, _ := b.Pick(key); again.ID != instance.ID {
+			t.Fatalf("key %s picked %s and %s", key, instance.ID, again.ID)
+		}
+	}
+	// keys not on removed instance should keep their instances
+	d.Unregister(context.Background(), "foo", "2")
+	if err := b.Refresh(context.Background()); err != nil {
+		t.Fatalf("refresh error: %v", err)
+	}
+	for _, key := range keys {
+		instance, _ := b.Pick(key)
+		if picked[key] != "2" && instance.ID != picked[key] {
+			t.Fatalf("key %s moved from %s to %s", key, picked[key], instance.ID)
+		}
+	}
+}
+
+func TestNoInstance(t *testing.T) {
+	b := balancer.New(memory.New(), "foo", balancer.WithStrategy(balancer.Random()))
+	if err := b.Init(); err != nil {
+		t.Fatalf("init error: %v", err)
+	}
+	if _, err := b.Pick(""); err != balancer.ErrNoInstance {
+		t.Fatalf("want ErrNoInstance, but got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package balancer
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// Strategy picks an instance from available instances. Methods of Strategy
+// are called with the Balancer locked, so implementations need not be
+// concurrent-safe.
+type Strategy interface {
+	// Update is called after available instances changed, instances sorted by id
+	Update(instances []Instance)
+	// Pick picks an instance by key, false returned if no instance picked
+	Pick(key string) (Instance, bool)
+}
+
+// RoundRobin returns a Strategy which picks instances in turn
+func RoundRobin() Strategy {
+	return &roundRobin{}
+}
+
+type roundRobin struct {
+	instances []Instance
+	next      int
+}
+
+func (s *roundRobin) Update(instances []Instance) {
+	s.instances = instances
+}
+
+func (s *roundRobin) Pick(key string) (Instance, bool) {
+	if len(s.instances) == 0 {
+		return Instance{}, false
+	}
+	s.next %= len(s.instances)
+	instance := s.instances[s.next]
+	s.next++
+	return instance, true
+}
+
+// Random returns a Strategy which picks instances randomly
+func Random() Strategy {
+	return &random{}
+}
+
+type random struct {
+	instances []Instance
+}
+
+func (s *random) Update(instances []Instance) {
+	s.instances = instances
+}
+
+func (s *random) Pick(key string) (Instance, bool) {
+	if len(s.instances) == 0 {
+		return Instance{}, false
+	}
+	return s.instances[rand.Intn(len(s.instances))], true
+}
+
+// Weighted returns a Strategy which picks instances by smooth weighted round-robin,
+// instances with weight <= 0 are never picked.
+func Weighted(weight func(Instance) int) Strategy {
+	return &weighted{weight: weight}
+}
+
+type weightedInstance struct {
+	instance Instance
+	weight   int
+	current  int
+}
+
+type weighted struct {
+	weight    func(Instance) int
+	instances []weightedInstance
+	total     int
+}
+
+func (s *weighted) Update(instances []Instance) {
+	s.instances = s.instances[:0]
+	s.total = 0
+	for _, instance := range instances {
+		w := s.weight(instance)
+		if w <= 0 {
+			continue
+		}
+		s.instances = append(s.instances, weightedInstance{
+			instance: instance,
+			weight:   w,
+		})
+		s.total += w
+	}
+}
+
+func (s *weighted) Pick(key string) (Instance, bool) {
+	var best *weightedInstance
+	for i := range s.instances {
+		wi := &s.instances[i]
+		wi.current += wi.weight
+		if best == nil || wi.current > best.current {
+			best = wi
+		}
+	}
+	if best == nil {
+		return Instance{}, false
+	}
+	best.current -= s.total
+	return best.instance, true
+}
+
+// ConsistentHash returns a Strategy which picks instances by consistent hashing
+// of the key, each instance has replicas virtual nodes on the hash ring.
+func ConsistentHash(replicas int) Strategy {
+	if replicas <= 0 {
+		replicas = 1
+	}
+	return &consistentHash{replicas: replicas}
+}
+
+type node struct {
+	hash  uint32
+	index int
+}
+
+type consistentHash struct {
+	replicas  int
+	instances []Instance
+	ring      []node
+}
+
+func hashString(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+func (s *consistentHash) Update(instances []Instance) {
+	s.instances = instances
+	s.ring = s.ring[:0]
+	for i, instance := range instances {
+		for j := 0; j < s.replicas; j++ {
+			s.ring = append(s.ring, node{
+				hash:  hashString(instance.ID + "#" + strconv.Itoa(j)),
+				index: i,
+			})
+		}
+	}
+	sort.Slice(s.ring, func(i, j int) bool {
+		return s.ring[i].hash < s.ring[j].hash
+	})
+}
+
+func (s *consistentHash) Pick(key string) (Instance, bool) {
+	if len(s.ring) == 0 {
+		return Instance{}, false
+	}
+	h := hashString(key)
+	i := sort.Search(len(s.ring), func(i int) bool {
+		return s.ring[i].hash >= h
+	})
+	if i == len(s.ring) {
+		i = 0
+	}
+	return s.instances[s.ring[i].index], true
+}
@@ -16,6 +16,14 @@ func IsExist(err error) bool {
 	return errors.Is(err, ErrExist)
 }
 
+// ErrNotFound represents an error in case of service not found.
+var ErrNotFound = errors.New("discovery: not found")
+
+// IsNotFound reports whether the err is ErrNotFound
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
 // Discovery represents a interface for service discovery
 type Discovery interface {
 	// Register registers a service, if nx is true, the id must not exist.
@@ -23,9 +31,10 @@ type Discovery interface {
 	Register(ctx context.Context, name, id, content string, nx bool, ttl time.Duration) error
 	// Unregister unregisters a service
 	Unregister(ctx context.Context, name, id string) error
-	// Find finds service by name and id
+	// Find finds service by name and id, ErrNotFound returned if not found
 	Find(ctx context.Context, name, id string) (content string, err error)
-	// Resolve resolves any one service by name
+	// Resolve resolves any one service by name, ErrNotFound returned if
+	// no service found
 	Resolve(ctx context.Context, name string) (id, content string, err error)
 	// ResolveAll resolves all services by name
 	ResolveAll(ctx context.Context, name string) (map[string]string, error)
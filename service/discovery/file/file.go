@@ -0,0 +1,302 @@
+// Package file implements a file-system backed discovery driver registered as "file".
+//
+// The source is a directory, each service is stored as a file
+//
+//	<source>/<escaped name>/<escaped id>
+//
+// The first line of the file is the ttl in milliseconds (0 means never expired),
+// followed by the content. The modification time of the file is the time of the
+// last registration, so the service is expired once mtime+ttl has passed.
+//
+// Files are written to a temporary file first, and then moved to the target path:
+// by rename if nx is false, by hard link (which fails if the target exists) if nx
+// is true. So processes sharing the directory never see partially written files.
+// An expired file is renamed aside before it is removed, and it's restored if
+// it's not the one found expired, so a fresh registration is never removed.
+//
+// Names and ids "." and ".." are invalid since they escape the directory.
+//
+//	import _ "github.com/gopherd/doge/service/discovery/file"
+//
+//	d, err := discovery.Open("file", "/tmp/discovery")
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopherd/doge/service/discovery"
+)
+
+// tempPrefix is the name prefix of temporary files, it never conflicts with
+// escaped ids since '%' is always followed by hex digits in escaped names.
+const tempPrefix = "%tmp."
+
+func init() {
+	discovery.Register("file", driver{})
+}
+
+type driver struct{}
+
+// Open implements discovery.Driver Open method
+func (driver) Open(source string) (discovery.Discovery, error) {
+	return Open(source)
+}
+
+// Discovery implements discovery.Discovery based on file system
+type Discovery struct {
+	dir string
+}
+
+// Open creates a Discovery stores services in directory dir
+func Open(dir string) (*Discovery, error) {
+	if dir == "" {
+		return nil, errors.New("discovery/file: empty directory")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Discovery{dir: dir}, nil
+}
+
+// ErrInvalidName represents an error in case of name or id is "." or ".."
+var ErrInvalidName = errors.New("discovery/file: invalid name")
+
+func validate(names ...string) error {
+	for _, name := range names {
+		if name == "." || name == ".." {
+			return fmt.Errorf("%w %q", ErrInvalidName, name)
+		}
+	}
+	return nil
+}
+
+func (d *Discovery) dirof(name string) string {
+	return filepath.Join(d.dir, url.PathEscape(name))
+}
+
+func (d *Discovery) pathof(name, id string) string {
+	return filepath.Join(d.dirof(name), url.PathEscape(id))
+}
+
+type entry struct {
+	content  string
+	modified time.Time
+	ttl      time.Duration
+	info     fs.FileInfo
+}
+
+func (e entry) expired(now time.Time) bool {
+	return e.ttl > 0 && !now.Before(e.modified.Add(e.ttl))
+}
+
+func readEntry(path string) (entry, error) {
+	var e entry
+	f, err := os.Open(path)
+	if err != nil {
+		return e, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return e, err
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(f); err != nil {
+		return e, err
+	}
+	data := buf.Bytes()
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		return e, fmt.Errorf("discovery/file: malformed file %q", path)
+	}
+	ms, err := strconv.ParseInt(string(data[:i]), 10, 64)
+	if err != nil {
+		return e, fmt.Errorf("discovery/file: malformed ttl in file %q: %w", path, err)
+	}
+	e.content = string(data[i+1:])
+	e.modified = info.ModTime()
+	e.info = info
+	e.ttl = time.Duration(ms) * time.Millisecond
+	return e, nil
+}
+
+// find reads the unexpired entry, ErrNotFound returned if not found or expired
+func (d *Discovery) find(name, id string, now time.Time) (entry, error) {
+	e, err := readEntry(d.pathof(name, id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return e, discovery.ErrNotFound
+		}
+		return e, err
+	}
+	if e.expired(now) {
+		return e, discovery.ErrNotFound
+	}
+	return e, nil
+}
+
+// Register implements discovery.Discovery Register method
+func (d *Discovery) Register(ctx context.Context, name, id, content string, nx bool, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := validate(name, id); err != nil {
+		return err
+	}
+	dir := d.dirof(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	tmpname := tmp.Name()
+	defer os.Remove(tmpname)
+	if ttl < 0 {
+		ttl = 0
+	}
+	_, err = tmp.WriteString(strconv.FormatInt(ttl.Milliseconds(), 10) + "\n" + content)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	path := d.pathof(name, id)
+	if !nx {
+		return os.Rename(tmpname, path)
+	}
+	for {
+		err := os.Link(tmpname, path)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		e, err := readEntry(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		if !e.expired(time.Now()) {
+			return discovery.ErrExist
+		}
+		if err := removeExpired(path, tmpname+".expired", e.info); err != nil {
+			return err
+		}
+	}
+}
+
+// removeExpired removes the file at path if it's the same file as expired.
+// The file is renamed to the unique name aside first, and it's restored if
+// it has been replaced by another process after it was found expired.
+func removeExpired(path, aside string, expired fs.FileInfo) error {
+	if err := os.Rename(path, aside); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	info, err := os.Stat(aside)
+	if err == nil && !os.SameFile(info, expired) {
+		// restore the file unless the path has been taken again
+		if err = os.Link(aside, path); errors.Is(err, fs.ErrExist) {
+			err = nil
+		}
+	}
+	if e := os.Remove(aside); err == nil && !errors.Is(e, fs.ErrNotExist) {
+		err = e
+	}
+	return err
+}
+
+// Unregister implements discovery.Discovery Unregister method
+func (d *Discovery) Unregister(ctx context.Context, name, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := validate(name, id); err != nil {
+		return err
+	}
+	err := os.Remove(d.pathof(name, id))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Find implements discovery.Discovery Find method
+func (d *Discovery) Find(ctx context.Context, name, id string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := validate(name, id); err != nil {
+		return "", err
+	}
+	e, err := d.find(name, id, time.Now())
+	return e.content, err
+}
+
+// Resolve implements discovery.Discovery Resolve method
+func (d *Discovery) Resolve(ctx context.Context, name string) (id, content string, err error) {
+	all, err := d.ResolveAll(ctx, name)
+	if err != nil {
+		return "", "", err
+	}
+	for id, content := range all {
+		return id, content, nil
+	}
+	return "", "", discovery.ErrNotFound
+}
+
+// ResolveAll implements discovery.Discovery ResolveAll method
+func (d *Discovery) ResolveAll(ctx context.Context, name string) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := validate(name); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(d.dirof(name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	now := time.Now()
+	result := make(map[string]string, len(entries))
+	for _, de := range entries {
+		if de.IsDir() || strings.HasPrefix(de.Name(), tempPrefix) {
+			continue
+		}
+		id, err := url.PathUnescape(de.Name())
+		if err != nil {
+			continue
+		}
+		e, err := d.find(name, id, now)
+		if err != nil {
+			if discovery.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		result[id] = e.content
+	}
+	return result, nil
+}
@@ -0,0 +1,125 @@
+package file_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/service/discovery"
+	"github.com/gopherd/doge/service/discovery/file"
+)
+
+func TestDiscovery(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	d1, err := discovery.Open("file", dir)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	d2, _ := discovery.Open("file", dir)
+
+	const name = "message/router"
+	if err := d1.Register(ctx, name, "1", "a\nb", true, 0); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	if err := d2.Register(ctx, name, "1", "c", true, 0); !discovery.IsExist(err) {
+		t.Fatalf("want ErrExist, but got %v", err)
+	}
+	if content, err := d2.Find(ctx, name, "1"); err != nil || content != "a\nb" {
+		t.Fatalf("want content %q, but got %q, error %v", "a\nb", content, err)
+	}
+	if err := d2.Register(ctx, name, "1", "c", false, 0); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	if err := d2.Register(ctx, name, "2/x", "d", true, 20*time.Millisecond); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	all, err := d1.ResolveAll(ctx, name)
+	if err != nil || len(all) != 2 || all["1"] != "c" || all["2/x"] != "d" {
+		t.Fatalf("unexpected services %v, error %v", all, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := d1.Find(ctx, name, "2/x"); !discovery.IsNotFound(err) {
+		t.Fatalf("want ErrNotFound, but got %v", err)
+	}
+	if id, content, err := d1.Resolve(ctx, name); err != nil || id != "1" || content != "c" {
+		t.Fatalf("want service 1, but got %q:%q, error %v", id, content, err)
+	}
+	if err := d1.Register(ctx, name, "2/x", "e", true, 0); err != nil {
+		t.Fatalf("register expired id error: %v", err)
+	}
+
+	if err := d1.Unregister(ctx, name, "1"); err != nil {
+		t.Fatalf("unregister error: %v", err)
+	}
+	if err := d1.Unregister(ctx, name, "1"); err != nil {
+		t.Fatalf("unregister twice error: %v", err)
+	}
+	if all, err := d2.ResolveAll(ctx, name); err != nil || len(all) != 1 {
+		t.Fatalf("want 1 service, but got %v, error %v", all, err)
+	}
+	if all, err := d2.ResolveAll(ctx, "unknown"); err != nil || len(all) != 0 {
+		t.Fatalf("want no services, but got %v, error %v", all, err)
+	}
+}
+
+func TestRegisterExpired(t *testing.T) {
+	ctx := context.Background()
+	d, err := discovery.Open("file", t.TempDir())
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	const name = "gate"
+	if err := d.Register(ctx, name, "1", "old", true, time.Millisecond); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	var (
+		wg      sync.WaitGroup
+		succeed int32
+	)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := d.Register(ctx, name, "1", strconv.Itoa(i), true, 0)
+			if err == nil {
+				atomic.AddInt32(&succeed, 1)
+			} else if !discovery.IsExist(err) {
+				t.Errorf("register error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if succeed != 1 {
+		t.Fatalf("want 1 registration succeed, but got %d", succeed)
+	}
+	if all, err := d.ResolveAll(ctx, name); err != nil || len(all) != 1 || all["1"] == "old" {
+		t.Fatalf("unexpected services %v, error %v", all, err)
+	}
+}
+
+func TestInvalidName(t *testing.T) {
+	ctx := context.Background()
+	d, err := discovery.Open("file", t.TempDir())
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	for _, x := range []struct{ name, id string }{{"..", "1"}, {".", "1"}, {"gate", ".."}, {"gate", "."}} {
+		if err := d.Register(ctx, x.name, x.id, "a", false, 0); !errors.Is(err, file.ErrInvalidName) {
+			t.Errorf("register %q/%q: want ErrInvalidName, but got %v", x.name, x.id, err)
+		}
+		if _, err := d.Find(ctx, x.name, x.id); !errors.Is(err, file.ErrInvalidName) {
+			t.Errorf("find %q/%q: want ErrInvalidName, but got %v", x.name, x.id, err)
+		}
+	}
+	if _, err := d.ResolveAll(ctx, ".."); !errors.Is(err, file.ErrInvalidName) {
+		t.Errorf("resolve all: want ErrInvalidName, but got %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+// Package memory implements an in-process discovery driver registered as "memory".
+//
+// Discoveries opened with the same source share the same storage, so that
+// several services running in one process (e.g. in tests) can discover each other:
+//
+//	import _ "github.com/gopherd/doge/service/discovery/memory"
+//
+//	d, err := discovery.Open("memory", "cluster1")
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gopherd/doge/service/discovery"
+)
+
+func init() {
+	discovery.Register("memory", driver{})
+}
+
+type driver struct{}
+
+// Open implements discovery.Driver Open method
+func (driver) Open(source string) (discovery.Discovery, error) {
+	return Open(source), nil
+}
+
+var (
+	mu     sync.Mutex
+	shared = make(map[string]*Discovery)
+)
+
+// Open returns the shared in-memory discovery by source
+func Open(source string) *Discovery {
+	mu.Lock()
+	defer mu.Unlock()
+	d, ok := shared[source]
+	if !ok {
+		d = New()
+		shared[source] = d
+	}
+	return d
+}
+
+type entry struct {
+	content string
+	expires time.Time   // zero if never expired
+	timer   *time.Timer // timer to remove the expired entry
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// Discovery implements discovery.Discovery and discovery.Watcher in memory
+type Discovery struct {
+	mu       sync.RWMutex
+	services map[string]map[string]*entry // name => id => entry
+	watchers map[string]map[*watcher]struct{}
+}
+
+// New creates a standalone in-memory Discovery which is not shared by source
+func New() *Discovery {
+	return &Discovery{
+		services: make(map[string]map[string]*entry),
+		watchers: make(map[string]map[*watcher]struct{}),
+	}
+}
+
+// Register implements discovery.Discovery Register method
+func (d *Discovery) Register(ctx context.Context, name, id, content string, nx bool, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ids, ok := d.services[name]
+	if !ok {
+		ids = make(map[string]*entry)
+		d.services[name] = ids
+	}
+	if old, ok := ids[id]; ok {
+		if nx && !old.expired(now) {
+			return discovery.ErrExist
+		}
+		if old.timer != nil {
+			old.timer.Stop()
+		}
+	}
+	e := &entry{content: content}
+	if ttl > 0 {
+		e.expires = now.Add(ttl)
+		e.timer = time.AfterFunc(ttl, func() {
+			d.expire(name, id, e)
+		})
+	}
+	ids[id] = e
+	d.notify(discovery.Event{Type: discovery.Put, Name: name, ID: id, Content: content})
+	return nil
+}
+
+func (d *Discovery) expire(name, id string, e *entry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.services[name][id] == e {
+		d.remove(name, id)
+	}
+}
+
+// remove removes the entry and notifies watchers, d.mu must be locked
+func (d *Discovery) remove(name, id string) {
+	ids, ok := d.services[name]
+	if !ok {
+		return
+	}
+	e, ok := ids[id]
+	if !ok {
+		return
+	}
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	delete(ids, id)
+	if len(ids) == 0 {
+		delete(d.services, name)
+	}
+	d.notify(discovery.Event{Type: discovery.Delete, Name: name, ID: id})
+}
+
+// Unregister implements discovery.Discovery Unregister method
+func (d *Discovery) Unregister(ctx context.Context, name, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.remove(name, id)
+	return nil
+}
+
+// Find implements discovery.Discovery Find method
+func (d *Discovery) Find(ctx context.Context, name, id string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if e, ok := d.services[name][id]; ok && !e.expired(now) {
+		return e.content, nil
+	}
+	return "", discovery.ErrNotFound
+}
+
+// Resolve implements discovery.Discovery Resolve method
+func (d *Discovery) Resolve(ctx context.Context, name string) (id, content string, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	now := time.Now()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for k, e := range d.services[name] {
+		if !e.expired(now) {
+			return k, e.content, nil
+		}
+	}
+	return "", "", discovery.ErrNotFound
+}
+
+// ResolveAll implements discovery.Discovery ResolveAll method
+func (d *Discovery) ResolveAll(ctx context.Context, name string) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	ids := d.services[name]
+	result := make(map[string]string, len(ids))
+	for id, e := range ids {
+		if !e.expired(now) {
+			result[id] = e.content
+		}
+	}
+	return result, nil
+}
+
+// Watch implements discovery.Watcher Watch method
+func (d *Discovery) Watch(ctx context.Context, name string) (<-chan discovery.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	w := &watcher{notified: make(chan struct{}, 1)}
+	now := time.Now()
+	d.mu.Lock()
+	for id, e := range d.services[name] {
+		if !e.expired(now) {
+			w.push(discovery.Event{Type: discovery.Put, Name: name, ID: id, Content: e.content})
+		}
+	}
+	ws, ok := d.watchers[name]
+	if !ok {
+		ws = make(map[*watcher]struct{})
+		d.watchers[name] = ws
+	}
+	ws[w] = struct{}{}
+	d.mu.Unlock()
+
+	ch := make(chan discovery.Event)
+	go func() {
+		w.run(ctx, ch)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(ws, w)
+		if len(ws) == 0 {
+			delete(d.watchers, name)
+		}
+	}()
+	return ch, nil
+}
+
+// notify pushes the event to watchers, d.mu must be locked
+func (d *Discovery) notify(e discovery.Event) {
+	for w := range d.watchers[e.Name] {
+		w.push(e)
+	}
+}
+
+// watcher queues events without blocking the discovery
+type watcher struct {
+	mu       sync.Mutex
+	events   []discovery.Event
+	notified chan struct{}
+}
+
+func (w *watcher) push(e discovery.Event) {
+	w.mu.Lock()
+	w.events = append(w.events, e)
+	w.mu.Unlock()
+	select {
+	case w.notified <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) run(ctx context.Context, ch chan<- discovery.Event) {
+	defer close(ch)
+	for {
+		w.mu.Lock()
+		events := w.events
+		w.events = nil
+		w.mu.Unlock()
+		for _, e := range events {
+			select {
+			case ch <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-w.notified:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
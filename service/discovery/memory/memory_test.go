@@ -0,0 +1,81 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/service/discovery"
+	"github.com/gopherd/doge/service/discovery/memory"
+)
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	d := memory.New()
+	if err := d.Register(ctx, "foo", "1", "a", true, 0); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	if err := d.Register(ctx, "foo", "1", "b", true, 0); !discovery.IsExist(err) {
+		t.Fatalf("want ErrExist, but got %v", err)
+	}
+	if err := d.Register(ctx, "foo", "1", "b", false, 0); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	if content, err := d.Find(ctx, "foo", "1"); err != nil || content != "b" {
+		t.Fatalf("want content b, but got %q, error %v", content, err)
+	}
+	if err := d.Unregister(ctx, "foo", "1"); err != nil {
+		t.Fatalf("unregister error: %v", err)
+	}
+	if _, err := d.Find(ctx, "foo", "1"); !discovery.IsNotFound(err) {
+		t.Fatalf("want ErrNotFound, but got %v", err)
+	}
+	if _, _, err := d.Resolve(ctx, "foo"); !discovery.IsNotFound(err) {
+		t.Fatalf("want ErrNotFound, but got %v", err)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	ctx := context.Background()
+	d := memory.New()
+	if err := d.Register(ctx, "foo", "1", "a", true, 20*time.Millisecond); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	if err := d.Register(ctx, "foo", "2", "b", true, 0); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	if all, err := d.ResolveAll(ctx, "foo"); err != nil || len(all) != 2 {
+		t.Fatalf("want 2 services, but got %v, error %v", all, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	all, err := d.ResolveAll(ctx, "foo")
+	if err != nil || len(all) != 1 || all["2"] != "b" {
+		t.Fatalf("want only service 2, but got %v, error %v", all, err)
+	}
+	if id, content, err := d.Resolve(ctx, "foo"); err != nil || id != "2" || content != "b" {
+		t.Fatalf("want service 2, but got %q:%q, error %v", id, content, err)
+	}
+	if err := d.Register(ctx, "foo", "1", "c", true, 0); err != nil {
+		t.Fatalf("register expired id error: %v", err)
+	}
+}
+
+func TestShared(t *testing.T) {
+	ctx := context.Background()
+	d1, err := discovery.Open("memory", "TestShared")
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	d2, _ := discovery.Open("memory", "TestShared")
+	d3, _ := discovery.Open("memory", "TestShared.other")
+	if err := d1.Register(ctx, "foo", "1", "a", true, 0); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	defer d1.Unregister(ctx, "foo", "1")
+	if content, err := d2.Find(ctx, "foo", "1"); err != nil || content != "a" {
+		t.Fatalf("want content a, but got %q, error %v", content, err)
+	}
+	if _, err := d3.Find(ctx, "foo", "1"); !discovery.IsNotFound(err) {
+		t.Fatalf("want ErrNotFound, but got %v", err)
+	}
+}
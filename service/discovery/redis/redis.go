@@ -0,0 +1,355 @@
+// Package redis implements a discovery driver registered as "redis" which
+// speaks RESP to Redis or any RESP-compatible store.
+//
+// The source is an address like "127.0.0.1:6379" or an url like
+//
+//	redis://[:password@]host:port[/db][?prefix=discovery/]
+//
+// Each service stored as a key "<prefix><escaped name>/<escaped id>".
+//
+//	import _ "github.com/gopherd/doge/service/discovery/redis"
+//
+//	d, err := discovery.Open("redis", "redis://127.0.0.1:6379/0")
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopherd/doge/service/discovery"
+	"github.com/gopherd/doge/text/resp"
+)
+
+const (
+	defaultPrefix  = "discovery/"
+	defaultTimeout = 3 * time.Second
+	scanCount      = "128"
+)
+
+func init() {
+	discovery.Register("redis", driver{})
+}
+
+type driver struct{}
+
+// Open implements discovery.Driver Open method
+func (driver) Open(source string) (discovery.Discovery, error) {
+	return Open(source)
+}
+
+// Discovery implements discovery.Discovery by RESP
+type Discovery struct {
+	prefix string
+	client *client
+}
+
+// Open creates a Discovery connects to the source
+func Open(source string) (*Discovery, error) {
+	d := &Discovery{
+		prefix: defaultPrefix,
+		client: &client{
+			addr:    source,
+			timeout: defaultTimeout,
+			request: resp.NewValue(),
+			reply:   resp.NewValue(),
+		},
+	}
+	if strings.Contains(source, "://") {
+		u, err := url.Parse(source)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "redis" {
+			return nil, fmt.Errorf("discovery/redis: unsupported scheme %q", u.Scheme)
+		}
+		d.client.addr = u.Host
+		if u.User != nil {
+			d.client.password, _ = u.User.Password()
+		}
+		if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+			if d.client.db, err = strconv.Atoi(db); err != nil {
+				return nil, fmt.Errorf("discovery/redis: invalid db %q", db)
+			}
+		}
+		if prefix, ok := u.Query()["prefix"]; ok && len(prefix) > 0 {
+			d.prefix = prefix[0]
+		}
+	}
+	if d.client.addr == "" {
+		return nil, errors.New("discovery/redis: empty address")
+	}
+	return d, nil
+}
+
+// Close closes the underlying connection
+func (d *Discovery) Close() error {
+	return d.client.close()
+}
+
+func (d *Discovery) keyprefix(name string) string {
+	return d.prefix + url.PathEscape(name) + "/"
+}
+
+func (d *Discovery) keyof(name, id string) string {
+	return d.keyprefix(name) + url.PathEscape(id)
+}
+
+// Register implements discovery.Discovery Register method
+func (d *Discovery) Register(ctx context.Context, name, id, content string, nx bool, ttl time.Duration) error {
+	args := []string{"SET", d.keyof(name, id), content}
+	if ttl > 0 {
+		ms := ttl.Milliseconds()
+		if ms <= 0 {
+			ms = 1
+		}
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+	if nx {
+		args = append(args, "NX")
+	}
+	r, err := d.client.do(ctx, args...)
+	if err != nil {
+		return err
+	}
+	if r.isNil {
+		return discovery.ErrExist
+	}
+	return nil
+}
+
+// Unregister implements discovery.Discovery Unregister method
+func (d *Discovery) Unregister(ctx context.Context, name, id string) error {
+	_, err := d.client.do(ctx, "DEL", d.keyof(name, id))
+	return err
+}
+
+// Find implements discovery.Discovery Find method
+func (d *Discovery) Find(ctx context.Context, name, id string) (string, error) {
+	r, err := d.client.do(ctx, "GET", d.keyof(name, id))
+	if err != nil {
+		return "", err
+	}
+	if r.isNil {
+		return "", discovery.ErrNotFound
+	}
+	return r.str, nil
+}
+
+// Resolve implements discovery.Discovery Resolve method
+func (d *Discovery) Resolve(ctx context.Context, name string) (id, content string, err error) {
+	var (
+		prefix = d.keyprefix(name)
+		found  bool
+	)
+	err = d.scan(ctx, prefix, func(keys []string) (bool, error) {
+		for _, key := range keys {
+			r, err := d.client.do(ctx, "GET", key)
+			if err != nil {
+				return false, err
+			}
+			if r.isNil {
+				continue
+			}
+			if id, err = url.PathUnescape(key[len(prefix):]); err != nil {
+				continue
+			}
+			content = r.str
+			found = true
+			return false, nil
+		}
+		return true, nil
+	})
+	if err == nil && !found {
+		err = discovery.ErrNotFound
+	}
+	return
+}
+
+// ResolveAll implements discovery.Discovery ResolveAll method
+func (d *Discovery) ResolveAll(ctx context.Context, name string) (map[string]string, error) {
+	prefix := d.keyprefix(name)
+	result := make(map[string]string)
+	err := d.scan(ctx, prefix, func(keys []string) (bool, error) {
+		if len(keys) == 0 {
+			return true, nil
+		}
+		r, err := d.client.do(ctx, append([]string{"MGET"}, keys...)...)
+		if err != nil {
+			return false, err
+		}
+		if len(r.elements) != len(keys) {
+			return false, errors.New("discovery/redis: unexpected MGET reply")
+		}
+		for i, key := range keys {
+			if r.elements[i].isNil {
+				continue
+			}
+			if id, err := url.PathUnescape(key[len(prefix):]); err == nil {
+				result[id] = r.elements[i].str
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// scan scans keys with the prefix, and calls fn for each batch of keys until fn returns false
+func (d *Discovery) scan(ctx context.Context, prefix string, fn func(keys []string) (bool, error)) error {
+	cursor := "0"
+	for {
+		r, err := d.client.do(ctx, "SCAN", cursor, "MATCH", prefix+"*", "COUNT", scanCount)
+		if err != nil {
+			return err
+		}
+		if len(r.elements) != 2 {
+			return errors.New("discovery/redis: unexpected SCAN reply")
+		}
+		cursor = r.elements[0].str
+		keys := make([]string, 0, len(r.elements[1].elements))
+		for _, e := range r.elements[1].elements {
+			keys = append(keys, e.str)
+		}
+		if more, err := fn(keys); err != nil || !more {
+			return err
+		}
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
+
+// reply holds a decoded RESP value
+type reply struct {
+	typ      resp.Type
+	str      string
+	isNil    bool
+	elements []reply
+}
+
+func decode(v *resp.Value) reply {
+	r := reply{
+		typ:   v.Type,
+		isNil: v.IsNil(),
+	}
+	if v.Type == resp.ArrayType {
+		elements := v.Elements()
+		r.elements = make([]reply, len(elements))
+		for i := range elements {
+			r.elements[i] = decode(elements[i])
+		}
+	} else {
+		r.str = string(v.Value())
+	}
+	return r
+}
+
+// client is a RESP client over a single connection which reconnects on network errors
+type client struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	bufr    *bufio.Reader
+	bufw    *bufio.Writer
+	request *resp.Value
+	reply   *resp.Value
+}
+
+func (c *client) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *client) connect(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.bufr = bufio.NewReader(conn)
+	c.bufw = bufio.NewWriter(conn)
+	if c.password != "" {
+		if _, err := c.roundtrip(ctx, "AUTH", c.password); err != nil {
+			return err
+		}
+	}
+	if c.db != 0 {
+		if _, err := c.roundtrip(ctx, "SELECT", strconv.Itoa(c.db)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *client) do(ctx context.Context, args ...string) (reply, error) {
+	if err := ctx.Err(); err != nil {
+		return reply{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			if c.conn != nil {
+				c.conn.Close()
+				c.conn = nil
+			}
+			return reply{}, err
+		}
+	}
+	return c.roundtrip(ctx, args...)
+}
+
+func (c *client) roundtrip(ctx context.Context, args ...string) (reply, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.timeout)
+	}
+	c.conn.SetDeadline(deadline)
+
+	values := make([]any, len(args))
+	for i := range args {
+		values[i] = args[i]
+	}
+	if err := c.request.Set(values); err != nil {
+		return reply{}, err
+	}
+	_, err := c.request.WriteTo(c.bufw)
+	if err == nil {
+		err = c.bufw.Flush()
+	}
+	if err == nil {
+		err = c.reply.ReadFrom(c.bufr)
+	}
+	if err != nil {
+		// the connection is broken, reconnect on next call
+		c.conn.Close()
+		c.conn = nil
+		return reply{}, err
+	}
+	r := decode(c.reply)
+	if r.typ == resp.ErrorType {
+		return r, errors.New("discovery/redis: " + r.str)
+	}
+	return r, nil
+}
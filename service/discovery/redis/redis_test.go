@@ -0,0 +1,207 @@
+package redis_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/service/discovery"
+	"github.com/gopherd/doge/service/discovery/redis"
+	"github.com/gopherd/doge/text/resp"
+)
+
+type item struct {
+	value   string
+	expires time.Time
+}
+
+// server is a minimal RESP server which supports commands used by the driver
+type server struct {
+	mu    sync.Mutex
+	items map[string]item
+}
+
+func (s *server) get(key string) (string, bool) {
+	it, ok := s.items[key]
+	if !ok {
+		return "", false
+	}
+	if !it.expires.IsZero() && !time.Now().Before(it.expires) {
+		delete(s.items, key)
+		return "", false
+	}
+	return it.value, true
+}
+
+func (s *server) serve(conn net.Conn) {
+	defer conn.Close()
+	var (
+		r     = bufio.NewReader(conn)
+		w     = bufio.NewWriter(conn)
+		cmd   = resp.NewCommand()
+		reply = resp.NewValue()
+	)
+	for {
+		if err := cmd.Request.ReadFrom(r); err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.exec(cmd, reply)
+		s.mu.Unlock()
+		reply.WriteTo(w)
+		if w.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (s *server) exec(cmd *resp.Command, reply *resp.Value) {
+	switch strings.ToUpper(cmd.Name()) {
+	case "SET":
+		key, value := cmd.Arg(0), cmd.Arg(1)
+		var (
+			it = item{value: value}
+			nx bool
+		)
+		for i := 2; i < cmd.NArg(); i++ {
+			switch strings.ToUpper(cmd.Arg(i)) {
+			case "NX":
+				nx = true
+			case "PX":
+				i++
+				ms, _ := strconv.ParseInt(cmd.Arg(i), 10, 64)
+				it.expires = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+		}
+		if _, ok := s.get(key); ok && nx {
+			reply.SetNil()
+			return
+		}
+		s.items[key] = it
+		reply.SetString("OK")
+	case "GET":
+		if value, ok := s.get(cmd.Arg(0)); ok {
+			reply.SetBytes([]byte(value))
+		} else {
+			reply.SetNil()
+		}
+	case "MGET":
+		values := make([]any, cmd.NArg())
+		for i := range values {
+			if value, ok := s.get(cmd.Arg(i)); ok {
+				values[i] = value
+			}
+		}
+		reply.Set(values)
+	case "DEL":
+		n := 0
+		for i := 0; i < cmd.NArg(); i++ {
+			if _, ok := s.get(cmd.Arg(i)); ok {
+				delete(s.items, cmd.Arg(i))
+				n++
+			}
+		}
+		reply.SetInteger(int64(n))
+	case "SCAN":
+		// returns one key per call to exercise the cursor
+		cursor, _ := strconv.Atoi(cmd.Arg(0))
+		pattern := cmd.Arg(2)
+		var keys []string
+		for key := range s.items {
+			if ok, _ := path.Match(pattern, key); ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		var (
+			next  = "0"
+			batch = []any{}
+		)
+		if cursor < len(keys) {
+			batch = append(batch, keys[cursor])
+			if cursor+1 < len(keys) {
+				next = strconv.Itoa(cursor + 1)
+			}
+		}
+		reply.Set([]any{next, batch})
+	default:
+		reply.SetError(errors.New("ERR unknown command '" + cmd.Name() + "'"))
+	}
+}
+
+func listen(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &server{items: make(map[string]item)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestDiscovery(t *testing.T) {
+	ctx := context.Background()
+	d, err := discovery.Open("redis", "redis://"+listen(t)+"?prefix=test/")
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	defer d.(*redis.Discovery).Close()
+
+	const name = "message/router"
+	if err := d.Register(ctx, name, "1", "a\r\nb", true, 0); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	if err := d.Register(ctx, name, "1", "c", true, 0); !discovery.IsExist(err) {
+		t.Fatalf("want ErrExist, but got %v", err)
+	}
+	if content, err := d.Find(ctx, name, "1"); err != nil || content != "a\r\nb" {
+		t.Fatalf("want content %q, but got %q, error %v", "a\r\nb", content, err)
+	}
+	if err := d.Register(ctx, name, "1", "c", false, 0); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	if err := d.Register(ctx, name, "2*", "d", true, 20*time.Millisecond); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	if err := d.Register(ctx, name+"/x", "3", "e", true, 0); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	all, err := d.ResolveAll(ctx, name)
+	if err != nil || len(all) != 2 || all["1"] != "c" || all["2*"] != "d" {
+		t.Fatalf("unexpected services %v, error %v", all, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := d.Find(ctx, name, "2*"); !discovery.IsNotFound(err) {
+		t.Fatalf("want ErrNotFound, but got %v", err)
+	}
+	if id, content, err := d.Resolve(ctx, name); err != nil || id != "1" || content != "c" {
+		t.Fatalf("want service 1, but got %q:%q, error %v", id, content, err)
+	}
+	if err := d.Unregister(ctx, name, "1"); err != nil {
+		t.Fatalf("unregister error: %v", err)
+	}
+	if _, _, err := d.Resolve(ctx, name); !discovery.IsNotFound(err) {
+		t.Fatalf("want ErrNotFound, but got %v", err)
+	}
+	if all, err := d.ResolveAll(ctx, name+"/x"); err != nil || len(all) != 1 || all["3"] != "e" {
+		t.Fatalf("unexpected services %v, error %v", all, err)
+	}
+}
@@ -0,0 +1,124 @@
+package discovery
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// EventType represents type of watched event
+type EventType int
+
+const (
+	Put    EventType = iota // service registered or updated
+	Delete                  // service unregistered or expired
+)
+
+func (typ EventType) String() string {
+	switch typ {
+	case Put:
+		return "Put"
+	case Delete:
+		return "Delete"
+	default:
+		return "Unknown(" + strconv.Itoa(int(typ)) + ")"
+	}
+}
+
+// Event represents a change of service
+type Event struct {
+	Type    EventType
+	Name    string
+	ID      string
+	Content string // empty for Delete event
+}
+
+// Watcher is an optional interface which could be implemented by Discovery
+// to push changes of services.
+type Watcher interface {
+	// Watch watches changes of services by name. Put events of all existing
+	// services are sent first. The returned channel will be closed after ctx done.
+	Watch(ctx context.Context, name string) (<-chan Event, error)
+}
+
+// DefaultPollInterval is the default interval used by Watch for discovery
+// which doesn't implement Watcher.
+const DefaultPollInterval = time.Second
+
+// Watch watches changes of services by name. If d implements Watcher, it is used,
+// otherwise changes are detected by polling ResolveAll every DefaultPollInterval.
+func Watch(ctx context.Context, d Discovery, name string) (<-chan Event, error) {
+	if w, ok := d.(Watcher); ok {
+		return w.Watch(ctx, name)
+	}
+	return Poll(d, DefaultPollInterval).Watch(ctx, name)
+}
+
+// Poll returns a Watcher which detects changes by polling ResolveAll of d every interval
+func Poll(d Discovery, interval time.Duration) Watcher {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	return &poller{discovery: d, interval: interval}
+}
+
+type poller struct {
+	discovery Discovery
+	interval  time.Duration
+}
+
+// Watch implements Watcher Watch method
+func (p *poller) Watch(ctx context.Context, name string) (<-chan Event, error) {
+	services, err := p.discovery.ResolveAll(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan Event)
+	go p.run(ctx, name, services, ch)
+	return ch, nil
+}
+
+func (p *poller) run(ctx context.Context, name string, services map[string]string, ch chan<- Event) {
+	defer close(ch)
+	send := func(e Event) bool {
+		select {
+		case ch <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	last := map[string]string{}
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		for id, content := range services {
+			if old, ok := last[id]; !ok || old != content {
+				if !send(Event{Type: Put, Name: name, ID: id, Content: content}) {
+					return
+				}
+			}
+		}
+		for id := range last {
+			if _, ok := services[id]; !ok {
+				if !send(Event{Type: Delete, Name: name, ID: id}) {
+					return
+				}
+			}
+		}
+		last = services
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			// keep the last view and retry at next tick if polling failed
+			var err error
+			if services, err = p.discovery.ResolveAll(ctx, name); err == nil {
+				break
+			}
+		}
+	}
+}
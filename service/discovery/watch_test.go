@@ -0,0 +1,59 @@
+package discovery_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/service/discovery"
+	"github.com/gopherd/doge/service/discovery/memory"
+)
+
+func expectEvent(t *testing.T, ch <-chan discovery.Event, want discovery.Event) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		if got != want {
+			t.Fatalf("want event %+v, but got %+v", want, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("want event %+v, but timeout", want)
+	}
+}
+
+func testWatch(t *testing.T, w discovery.Watcher, d discovery.Discovery) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := d.Register(ctx, "foo", "1", "a", true, 0); err != nil {
+		t.Fatalf("register error: %v", err)
+	}
+	ch, err := w.Watch(ctx, "foo")
+	if err != nil {
+		t.Fatalf("watch error: %v", err)
+	}
+	expectEvent(t, ch, discovery.Event{Type: discovery.Put, Name: "foo", ID: "1", Content: "a"})
+
+	d.Register(ctx, "bar", "1", "x", true, 0)
+	d.Register(ctx, "foo", "2", "b", true, 0)
+	expectEvent(t, ch, discovery.Event{Type: discovery.Put, Name: "foo", ID: "2", Content: "b"})
+	d.Register(ctx, "foo", "2", "c", false, 0)
+	expectEvent(t, ch, discovery.Event{Type: discovery.Put, Name: "foo", ID: "2", Content: "c"})
+	d.Unregister(ctx, "foo", "1")
+	expectEvent(t, ch, discovery.Event{Type: discovery.Delete, Name: "foo", ID: "1"})
+	d.Register(ctx, "foo", "3", "d", true, 20*time.Millisecond)
+	expectEvent(t, ch, discovery.Event{Type: discovery.Put, Name: "foo", ID: "3", Content: "d"})
+	expectEvent(t, ch, discovery.Event{Type: discovery.Delete, Name: "foo", ID: "3"})
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestWatch(t *testing.T) {
+	d := memory.New()
+	testWatch(t, d, d)
+}
+
+func TestPoll(t *testing.T) {
+	d := memory.New()
+	testWatch(t, discovery.Poll(d, 5*time.Millisecond), d)
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gopherd/log"
+)
+
+// DefaultFPS is the default frames per second of Loop
+const DefaultFPS = 30
+
+// LoopOption represents options of NewLoop
+type LoopOption func(*loopOptions)
+
+type loopOptions struct {
+	fps       int
+	smoothing int
+	slowFrame time.Duration
+}
+
+// WithFPS specify frames per second of the loop
+func WithFPS(fps int) LoopOption {
+	return func(opt *loopOptions) {
+		if fps > 0 {
+			opt.fps = fps
+		}
+	}
+}
+
+// WithSmoothing specify the number of frames to smooth dt over, dt passed to
+// update is an exponential moving average of measured frame intervals.
+// 1 disables smoothing.
+func WithSmoothing(frames int) LoopOption {
+	return func(opt *loopOptions) {
+		if frames > 0 {
+			opt.smoothing = frames
+		}
+	}
+}
+
+// WithSlowFrame specify the threshold of logging slow frames, the frame
+// interval is used by default.
+func WithSlowFrame(threshold time.Duration) LoopOption {
+	return func(opt *loopOptions) {
+		if threshold > 0 {
+			opt.slowFrame = threshold
+		}
+	}
+}
+
+// Loop runs update at a fixed rate in a single goroutine. Closures posted by
+// Post are run in the loop goroutine before update of the next frame, so that
+// other goroutines could hand work to single-threaded logic.
+//
+// Frames are skipped rather than queued if a frame costs more than the frame
+// interval, which is counted as an overrun.
+type Loop struct {
+	update   func(now time.Time, dt time.Duration)
+	opt      loopOptions
+	interval time.Duration
+
+	mu     sync.Mutex
+	posted []func()
+
+	frames   int64
+	overruns int64
+
+	quit, wait chan struct{}
+	running    int32
+}
+
+// NewLoop creates a Loop which calls update per frame
+func NewLoop(update func(now time.Time, dt time.Duration), options ...LoopOption) *Loop {
+	l := &Loop{
+		update: update,
+		opt: loopOptions{
+			fps:       DefaultFPS,
+			smoothing: 8,
+		},
+		quit: make(chan struct{}),
+		wait: make(chan struct{}),
+	}
+	for i := range options {
+		options[i](&l.opt)
+	}
+	l.interval = time.Second / time.Duration(l.opt.fps)
+	if l.opt.slowFrame <= 0 {
+		l.opt.slowFrame = l.interval
+	}
+	return l
+}
+
+// Interval returns the frame interval
+func (l *Loop) Interval() time.Duration {
+	return l.interval
+}
+
+// Frames returns the number of frames updated
+func (l *Loop) Frames() int64 {
+	return atomic.LoadInt64(&l.frames)
+}
+
+// Overruns returns the number of frames which cost more than the frame interval
+func (l *Loop) Overruns() int64 {
+	return atomic.LoadInt64(&l.overruns)
+}
+
+// Post posts fn to run in the loop goroutine, it's safe for concurrent use.
+func (l *Loop) Post(fn func()) {
+	l.mu.Lock()
+	l.posted = append(l.posted, fn)
+	l.mu.Unlock()
+}
+
+// post posts fn like Post, but false returned without posting if the loop is
+// not running, i.e. fn would never run or run after Start.
+func (l *Loop) post(fn func()) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if atomic.LoadInt32(&l.running) == 0 {
+		return false
+	}
+	l.posted = append(l.posted, fn)
+	return true
+}
+
+// Start starts the loop in a new goroutine
+func (l *Loop) Start() {
+	if atomic.CompareAndSwapInt32(&l.running, 0, 1) {
+		go l.run()
+	}
+}
+
+// Shutdown stops the loop and waits until the current frame finished,
+// closures posted before Shutdown are run before it returns.
+func (l *Loop) Shutdown() {
+	if atomic.CompareAndSwapInt32(&l.running, 1, 0) {
+		close(l.quit)
+		<-l.wait
+	}
+}
+
+func (l *Loop) flush() {
+	for {
+		l.mu.Lock()
+		posted := l.posted
+		l.posted = nil
+		l.mu.Unlock()
+		if len(posted) == 0 {
+			return
+		}
+		for _, fn := range posted {
+			fn()
+		}
+	}
+}
+
+func (l *Loop) run() {
+	defer close(l.wait)
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	var (
+		last     = time.Now()
+		dt       = l.interval
+		slow     int64     // slow frames since last logged
+		loggedAt time.Time // last time of logging slow frames
+	)
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			elapsed := now.Sub(last)
+			last = now
+			dt += (elapsed - dt) / time.Duration(l.opt.smoothing)
+
+			l.flush()
+			l.update(now, dt)
+			atomic.AddInt64(&l.frames, 1)
+
+			cost := time.Since(now)
+			if cost > l.interval {
+				atomic.AddInt64(&l.overruns, 1)
+			}
+			if cost > l.opt.slowFrame {
+				slow++
+				// log at most once per second
+				if time.Since(loggedAt) >= time.Second {
+					log.Warn().
+						Duration("cost", cost).
+						Duration("interval", l.interval).
+						Int64("slow_frames", slow).
+						Print("slow frame")
+					slow = 0
+					loggedAt = time.Now()
+				}
+			}
+		case <-l.quit:
+			l.flush()
+			return
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package service_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/service"
+)
+
+func TestLoop(t *testing.T) {
+	var (
+		updates int64
+		posted  int64
+		dts     []time.Duration
+	)
+	loop := service.NewLoop(func(now time.Time, dt time.Duration) {
+		atomic.AddInt64(&updates, 1)
+		dts = append(dts, dt)
+	}, service.WithFPS(100))
+	if loop.Interval() != 10*time.Millisecond {
+		t.Fatalf("unexpected interval: %v", loop.Interval())
+	}
+	loop.Start()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loop.Post(func() {
+				// posted closures run in the loop goroutine, so it's safe to
+				// access state of update without locks
+				posted++
+			})
+		}()
+	}
+	wg.Wait()
+	time.Sleep(100 * time.Millisecond)
+	loop.Post(func() { posted++ })
+	loop.Shutdown()
+
+	if posted != 101 {
+		t.Errorf("want 101 posted closures run, got %d", posted)
+	}
+	if n := atomic.LoadInt64(&updates); n == 0 || n != loop.Frames() {
+		t.Errorf("updates %d, frames %d", n, loop.Frames())
+	}
+	for _, dt := range dts {
+		if dt <= 0 || dt > 100*time.Millisecond {
+			t.Errorf("unexpected dt: %v", dt)
+		}
+	}
+}
+
+func TestLoopOverrun(t *testing.T) {
+	loop := service.NewLoop(func(now time.Time, dt time.Duration) {
+		time.Sleep(15 * time.Millisecond)
+	}, service.WithFPS(100))
+	loop.Start()
+	time.Sleep(100 * time.Millisecond)
+	loop.Shutdown()
+	if loop.Frames() == 0 || loop.Overruns() != loop.Frames() {
+		t.Errorf("frames %d, overruns %d", loop.Frames(), loop.Overruns())
+	}
+}
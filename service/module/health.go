@@ -0,0 +1,109 @@
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Health represents health of module or service
+type Health int
+
+const (
+	Healthy   Health = iota // works well
+	Degraded                // works with reduced functionality
+	Unhealthy               // doesn't work
+)
+
+func (health Health) String() string {
+	switch health {
+	case Healthy:
+		return "healthy"
+	case Degraded:
+		return "degraded"
+	case Unhealthy:
+		return "unhealthy"
+	default:
+		return fmt.Sprintf("Health(%d)", int(health))
+	}
+}
+
+// MarshalJSON implements json.Marshaler MarshalJSON method
+func (health Health) MarshalJSON() ([]byte, error) {
+	switch health {
+	case Healthy, Degraded, Unhealthy:
+		return json.Marshal(health.String())
+	default:
+		return nil, fmt.Errorf("unknown health: %d", health)
+	}
+}
+
+// UnmarshalJSON implements json.Unmarshaler UnmarshalJSON method
+func (health *Health) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	switch strings.ToLower(s) {
+	case "healthy":
+		*health = Healthy
+	case "degraded":
+		*health = Degraded
+	case "unhealthy":
+		*health = Unhealthy
+	default:
+		return fmt.Errorf("unknown health: %q", string(data))
+	}
+	return nil
+}
+
+// HealthStatus represents result of health check
+type HealthStatus struct {
+	Health  Health `json:"health"`
+	Message string `json:"message,omitempty"`
+}
+
+// HealthChecker is an optional interface which could be implemented by Module
+// or Lifecycle to report its health, e.g. whether its dependencies are available.
+type HealthChecker interface {
+	// CheckHealth checks health of the module, it should return as soon as
+	// possible once ctx done.
+	CheckHealth(ctx context.Context) HealthStatus
+}
+
+// CheckHealth checks health of all modules which implement HealthChecker, and
+// returns the worst health as the aggregate health and statuses by module name.
+// A module is regarded as Unhealthy if it doesn't return before ctx done.
+func (m *Manager) CheckHealth(ctx context.Context) (Health, map[string]HealthStatus) {
+	var (
+		health   = Healthy
+		statuses map[string]HealthStatus
+	)
+	for _, e := range m.modules {
+		checker, ok := e.value.(HealthChecker)
+		if !ok {
+			continue
+		}
+		var (
+			status HealthStatus
+			done   = make(chan HealthStatus, 1)
+		)
+		go func() {
+			done <- checker.CheckHealth(ctx)
+		}()
+		select {
+		case status = <-done:
+		case <-ctx.Done():
+			status = HealthStatus{Health: Unhealthy, Message: ctx.Err().Error()}
+		}
+		if statuses == nil {
+			statuses = make(map[string]HealthStatus)
+		}
+		statuses[e.lifecycle.Name()] = status
+		if status.Health > health {
+			health = status.Health
+		}
+	}
+	return health, statuses
+}
@@ -0,0 +1,436 @@
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gopherd/log"
+
+	"github.com/gopherd/doge/metrics"
+)
+
+var updateDuration = metrics.MustRegister(metrics.NewHistogramVec(
+	"doge_module_update_seconds",
+	"Duration of module Update in seconds",
+	metrics.ExponentialBuckets(0.0001, 4, 8),
+	"module",
+))
+
+// Phase represents a lifecycle phase of modules
+type Phase int
+
+const (
+	PhaseInit Phase = iota
+	PhaseStart
+	PhaseShutdown
+	numPhase
+)
+
+func (phase Phase) String() string {
+	switch phase {
+	case PhaseInit:
+		return "init"
+	case PhaseStart:
+		return "start"
+	case PhaseShutdown:
+		return "shutdown"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrDependencyCycle represents an error in case of modules depend on each other
+var ErrDependencyCycle = errors.New("module: dependency cycle")
+
+// Error represents an error returned by a module in a lifecycle phase
+type Error struct {
+	Phase  Phase
+	Module string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("module: %s %s: %v", e.Phase, e.Module, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Lifecycle represents a module with context-aware lifecycle. Methods should
+// return as soon as possible once ctx done, the ctx is canceled if timeout of
+// the phase exceeded. The manager doesn't wait for a method which ignores ctx
+// after timeout: the phase fails with ctx.Err(), while the method keeps
+// running in its own goroutine and may run concurrently with methods of later
+// phases, e.g. Shutdown may be called while Init is still running.
+type Lifecycle interface {
+	// Name returns the name of module
+	Name() string
+	// Init initializes the module
+	Init(ctx context.Context) error
+	// Start starts the module
+	Start(ctx context.Context) error
+	// Shutdown shutdowns the module
+	Shutdown(ctx context.Context) error
+	// Update updates the module per frame
+	Update(time.Time, time.Duration)
+}
+
+// Dependent is an optional interface which could be implemented by Module or
+// Lifecycle to declare names of modules it depends on. A module is initialized
+// and started after its dependencies, and shut down before them.
+//
+// Modules which don't implement Dependent depend on the module added before them,
+// so that they are still initialized, started and shut down in insertion order.
+type Dependent interface {
+	Dependencies() []string
+}
+
+// Adapt wraps a Module as a Lifecycle
+func Adapt(mod Module) Lifecycle {
+	return adapter{mod}
+}
+
+type adapter struct {
+	mod Module
+}
+
+func (a adapter) Name() string                           { return a.mod.Name() }
+func (a adapter) Init(ctx context.Context) error         { return a.mod.Init() }
+func (a adapter) Start(ctx context.Context) error        { a.mod.Start(); return nil }
+func (a adapter) Shutdown(ctx context.Context) error     { a.mod.Shutdown(); return nil }
+func (a adapter) Update(now time.Time, dt time.Duration) { a.mod.Update(now, dt) }
+
+// entry holds an added module
+type entry struct {
+	value     any // Module or Lifecycle
+	lifecycle Lifecycle
+	updated   *metrics.Histogram
+}
+
+func (e entry) dependencies() ([]string, bool) {
+	if d, ok := e.value.(Dependent); ok {
+		return d.Dependencies(), true
+	}
+	return nil, false
+}
+
+// Manager used to manages a group of modules
+type Manager struct {
+	modules      []entry
+	type2modules map[reflect.Type][]int
+	timeouts     [numPhase]time.Duration
+}
+
+// NewManager creates a Manager
+func NewManager() *Manager {
+	return &Manager{
+		type2modules: make(map[reflect.Type][]int),
+	}
+}
+
+// SetTimeout sets timeout of the phase for all modules, zero means no timeout
+func (m *Manager) SetTimeout(phase Phase, timeout time.Duration) {
+	m.timeouts[phase] = timeout
+}
+
+// Add adds a module to the manager
+func (m *Manager) Add(mod Module) Module {
+	m.add(mod, Adapt(mod))
+	return mod
+}
+
+// AddLifecycle adds a context-aware module to the manager
+func (m *Manager) AddLifecycle(mod Lifecycle) Lifecycle {
+	m.add(mod, mod)
+	return mod
+}
+
+func (m *Manager) add(value any, lifecycle Lifecycle) {
+	t := reflect.TypeOf(value).Elem()
+	m.type2modules[t] = append(m.type2modules[t], len(m.modules))
+	m.modules = append(m.modules, entry{
+		value:     value,
+		lifecycle: lifecycle,
+		updated:   updateDuration.With(lifecycle.Name()),
+	})
+}
+
+// Find finds the first added module from the manager by type
+func (m *Manager) Find(t reflect.Type) Module {
+	for _, i := range m.type2modules[t] {
+		if mod, ok := m.modules[i].value.(Module); ok {
+			return mod
+		}
+	}
+	return nil
+}
+
+// FindAll finds all modules from the manager by type
+func (m *Manager) FindAll(t reflect.Type) []Module {
+	var mods []Module
+	for _, i := range m.type2modules[t] {
+		if mod, ok := m.modules[i].value.(Module); ok {
+			mods = append(mods, mod)
+		}
+	}
+	return mods
+}
+
+// Lookup finds the first added module from the manager by type as a Lifecycle,
+// modules added by Add are wrapped by Adapt.
+func (m *Manager) Lookup(t reflect.Type) Lifecycle {
+	if indices := m.type2modules[t]; len(indices) > 0 {
+		return m.modules[indices[0]].lifecycle
+	}
+	return nil
+}
+
+// Len returns the number of modules
+func (m *Manager) Len() int {
+	return len(m.modules)
+}
+
+// Get returns ith module, nil returned if the module is a Lifecycle added
+// by AddLifecycle.
+func (m *Manager) Get(i int) Module {
+	mod, _ := m.modules[i].value.(Module)
+	return mod
+}
+
+// GetLifecycle returns ith module as a Lifecycle
+func (m *Manager) GetLifecycle(i int) Lifecycle {
+	return m.modules[i].lifecycle
+}
+
+// levels sorts modules topologically, modules of the same level don't depend
+// on each other, and each module depends on modules of lower levels only.
+func (m *Manager) levels() ([][]int, error) {
+	var (
+		n       = len(m.modules)
+		names   = make(map[string][]int, n)
+		degrees = make([]int, n)
+		edges   = make([][]int, n) // dependency => dependents
+	)
+	for i, e := range m.modules {
+		name := e.lifecycle.Name()
+		names[name] = append(names[name], i)
+	}
+	for i, e := range m.modules {
+		deps, ok := e.dependencies()
+		if !ok {
+			if i > 0 {
+				edges[i-1] = append(edges[i-1], i)
+				degrees[i]++
+			}
+			continue
+		}
+		for _, dep := range deps {
+			indices, ok := names[dep]
+			if !ok {
+				return nil, fmt.Errorf("module: %s depends on unknown module %q", e.lifecycle.Name(), dep)
+			}
+			for _, j := range indices {
+				edges[j] = append(edges[j], i)
+				degrees[i]++
+			}
+		}
+	}
+	var (
+		levels [][]int
+		level  []int
+		sorted int
+	)
+	for i := range m.modules {
+		if degrees[i] == 0 {
+			level = append(level, i)
+		}
+	}
+	for len(level) > 0 {
+		levels = append(levels, level)
+		sorted += len(level)
+		var next []int
+		for _, i := range level {
+			for _, j := range edges[i] {
+				degrees[j]--
+				if degrees[j] == 0 {
+					next = append(next, j)
+				}
+			}
+		}
+		level = next
+	}
+	if sorted < n {
+		return nil, ErrDependencyCycle
+	}
+	return levels, nil
+}
+
+func (m *Manager) context(ctx context.Context, phase Phase) (context.Context, context.CancelFunc) {
+	if timeout := m.timeouts[phase]; timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// call calls fn in a new goroutine and returns ctx.Err() if ctx done before fn
+// returned, so that modules which ignore ctx can't block the phase forever.
+// The goroutine is abandoned then, see Lifecycle.
+func call(ctx context.Context, fn func(context.Context) error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Manager) run(ctx context.Context, phase Phase, mod Lifecycle) error {
+	var (
+		name = mod.Name()
+		fn   func(context.Context) error
+	)
+	switch phase {
+	case PhaseInit:
+		log.Info().String("module", name).Print("module initializing")
+		fn = mod.Init
+	case PhaseStart:
+		log.Info().String("module", name).Print("module starting")
+		fn = mod.Start
+	default:
+		log.Info().String("module", name).Print("module shutting down")
+		fn = mod.Shutdown
+	}
+	if err := call(ctx, fn); err != nil {
+		log.Info().
+			String("module", name).
+			String("phase", phase.String()).
+			Error("error", err).
+			Print("module lifecycle error")
+		return &Error{Phase: phase, Module: name, Err: err}
+	}
+	switch phase {
+	case PhaseInit:
+		log.Info().String("module", name).Print("module initialized")
+	case PhaseStart:
+		log.Info().String("module", name).Print("module started")
+	default:
+		log.Info().String("module", name).Print("module shutted down")
+	}
+	return nil
+}
+
+// InitContext initializes all modules in dependency order, independent modules
+// are initialized in parallel. It returns the first error occurred.
+func (m *Manager) InitContext(ctx context.Context) error {
+	levels, err := m.levels()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := m.context(ctx, PhaseInit)
+	defer cancel()
+	for _, level := range levels {
+		if len(level) == 1 {
+			if err := m.run(ctx, PhaseInit, m.modules[level[0]].lifecycle); err != nil {
+				return err
+			}
+			continue
+		}
+		var (
+			wg   sync.WaitGroup
+			errs = make([]error, len(level))
+		)
+		for i, index := range level {
+			wg.Add(1)
+			go func(i int, mod Lifecycle) {
+				defer wg.Done()
+				errs[i] = m.run(ctx, PhaseInit, mod)
+			}(i, m.modules[index].lifecycle)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// StartContext starts all modules in dependency order, it stops at the first error.
+func (m *Manager) StartContext(ctx context.Context) error {
+	levels, err := m.levels()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := m.context(ctx, PhaseStart)
+	defer cancel()
+	for _, level := range levels {
+		for _, i := range level {
+			if err := m.run(ctx, PhaseStart, m.modules[i].lifecycle); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ShutdownContext shutdowns all modules in reverse dependency order, all modules
+// are shut down even if some of them failed, and errors are joined.
+func (m *Manager) ShutdownContext(ctx context.Context) error {
+	levels, err := m.levels()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := m.context(ctx, PhaseShutdown)
+	defer cancel()
+	var errs []error
+	for i := len(levels) - 1; i >= 0; i-- {
+		level := levels[i]
+		for j := len(level) - 1; j >= 0; j-- {
+			if err := m.run(ctx, PhaseShutdown, m.modules[level[j]].lifecycle); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Init initializes all modules
+func (m *Manager) Init() error {
+	return m.InitContext(context.Background())
+}
+
+// Start starts all modules, errors are logged only, use StartContext instead
+// to handle errors.
+func (m *Manager) Start() {
+	if err := m.StartContext(context.Background()); err != nil {
+		log.Warn().Error("error", err).Print("start modules error")
+	}
+}
+
+// Shutdown shutdowns all modules in reverse order, errors are logged only,
+// use ShutdownContext instead to handle errors.
+func (m *Manager) Shutdown() {
+	if err := m.ShutdownContext(context.Background()); err != nil {
+		log.Warn().Error("error", err).Print("shutdown modules error")
+	}
+}
+
+// Update updates all modules in insertion order
+func (m *Manager) Update(now time.Time, dt time.Duration) {
+	for i := range m.modules {
+		e := &m.modules[i]
+		start := time.Now()
+		e.lifecycle.Update(now, dt)
+		e.updated.Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,196 @@
+package module_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/service/module"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) index(event string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, e := range r.events {
+		if e == event {
+			return i
+		}
+	}
+	return -1
+}
+
+type legacyModule struct {
+	*module.BasicModule
+	r *recorder
+}
+
+func (mod *legacyModule) Init() error {
+	mod.r.add("init " + mod.Name())
+	return nil
+}
+
+func (mod *legacyModule) Shutdown() {
+	mod.r.add("shutdown " + mod.Name())
+}
+
+type lifecycleModule struct {
+	name    string
+	deps    []string
+	r       *recorder
+	initErr error
+	block   bool
+	wait    *sync.WaitGroup
+}
+
+func (mod *lifecycleModule) Name() string                    { return mod.name }
+func (mod *lifecycleModule) Dependencies() []string          { return mod.deps }
+func (mod *lifecycleModule) Update(time.Time, time.Duration) {}
+
+func (mod *lifecycleModule) Init(ctx context.Context) error {
+	if mod.wait != nil {
+		// all modules of the level must be initialized in parallel
+		mod.wait.Done()
+		mod.wait.Wait()
+	}
+	if mod.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	mod.r.add("init " + mod.name)
+	return mod.initErr
+}
+
+func (mod *lifecycleModule) Start(ctx context.Context) error {
+	mod.r.add("start " + mod.name)
+	return nil
+}
+
+func (mod *lifecycleModule) Shutdown(ctx context.Context) error {
+	mod.r.add("shutdown " + mod.name)
+	return nil
+}
+
+func TestOrder(t *testing.T) {
+	var (
+		r    = new(recorder)
+		wait sync.WaitGroup
+		m    = module.NewManager()
+	)
+	wait.Add(2)
+	m.Add(&legacyModule{BasicModule: module.NewBasicModule("a"), r: r})
+	m.Add(&legacyModule{BasicModule: module.NewBasicModule("b"), r: r})
+	m.AddLifecycle(&lifecycleModule{name: "d", deps: []string{"c"}, r: r})
+	m.AddLifecycle(&lifecycleModule{name: "c", deps: []string{"a"}, r: r, wait: &wait})
+	m.AddLifecycle(&lifecycleModule{name: "e", deps: []string{"a"}, r: r, wait: &wait})
+
+	if err := m.InitContext(context.Background()); err != nil {
+		t.Fatalf("init error: %v", err)
+	}
+	if err := m.StartContext(context.Background()); err != nil {
+		t.Fatalf("start error: %v", err)
+	}
+	if err := m.ShutdownContext(context.Background()); err != nil {
+		t.Fatalf("shutdown error: %v", err)
+	}
+	for _, pair := range [][2]string{
+		{"init a", "init b"},
+		{"init a", "init c"},
+		{"init a", "init e"},
+		{"init c", "init d"},
+		{"start c", "start d"},
+		{"shutdown d", "shutdown c"},
+		{"shutdown b", "shutdown a"},
+		{"shutdown c", "shutdown a"},
+	} {
+		if i, j := r.index(pair[0]), r.index(pair[1]); i < 0 || j < 0 || i > j {
+			t.Errorf("%q should happen before %q: %v", pair[0], pair[1], r.events)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	r := new(recorder)
+
+	m := module.NewManager()
+	m.AddLifecycle(&lifecycleModule{name: "a", deps: []string{"b"}, r: r})
+	m.AddLifecycle(&lifecycleModule{name: "b", deps: []string{"a"}, r: r})
+	if err := m.InitContext(context.Background()); !errors.Is(err, module.ErrDependencyCycle) {
+		t.Errorf("want ErrDependencyCycle, got %v", err)
+	}
+
+	m = module.NewManager()
+	m.AddLifecycle(&lifecycleModule{name: "a", deps: []string{"x"}, r: r})
+	if err := m.InitContext(context.Background()); err == nil {
+		t.Errorf("want unknown dependency error")
+	}
+
+	initErr := errors.New("init failed")
+	m = module.NewManager()
+	m.AddLifecycle(&lifecycleModule{name: "a", r: r, initErr: initErr})
+	var e *module.Error
+	if err := m.InitContext(context.Background()); !errors.As(err, &e) || e.Module != "a" || e.Phase != module.PhaseInit || !errors.Is(err, initErr) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	m = module.NewManager()
+	m.SetTimeout(module.PhaseInit, 20*time.Millisecond)
+	m.AddLifecycle(&lifecycleModule{name: "a", r: r, block: true})
+	if err := m.InitContext(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want DeadlineExceeded, got %v", err)
+	}
+}
+
+type healthModule struct {
+	*module.BasicModule
+	status module.HealthStatus
+	block  bool
+}
+
+func (mod *healthModule) CheckHealth(ctx context.Context) module.HealthStatus {
+	if mod.block {
+		<-ctx.Done()
+	}
+	return mod.status
+}
+
+func TestCheckHealth(t *testing.T) {
+	m := module.NewManager()
+	m.Add(module.NewBasicModule("a"))
+	m.Add(&healthModule{BasicModule: module.NewBasicModule("b")})
+	health, statuses := m.CheckHealth(context.Background())
+	if health != module.Healthy || len(statuses) != 1 {
+		t.Fatalf("want healthy with 1 status, got %v %v", health, statuses)
+	}
+
+	m.Add(&healthModule{
+		BasicModule: module.NewBasicModule("c"),
+		status:      module.HealthStatus{Health: module.Degraded, Message: "cache unavailable"},
+	})
+	if health, _ := m.CheckHealth(context.Background()); health != module.Degraded {
+		t.Fatalf("want degraded, got %v", health)
+	}
+
+	m.Add(&healthModule{BasicModule: module.NewBasicModule("d"), block: true})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	health, statuses = m.CheckHealth(ctx)
+	if health != module.Unhealthy || statuses["d"].Health != module.Unhealthy {
+		t.Fatalf("want unhealthy, got %v %v", health, statuses)
+	}
+	if statuses["c"].Message != "cache unavailable" {
+		t.Fatalf("unexpected status of c: %+v", statuses["c"])
+	}
+}
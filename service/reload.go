@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/gopherd/log"
+
+	"github.com/gopherd/doge/config"
+	"github.com/gopherd/doge/os/signal"
+)
+
+// pending reload requests
+const (
+	reloadChanged = 1 + iota // config file may be changed
+	reloadForced             // reload even if content of config file not changed
+)
+
+// ReloadReport reports a reloading of config
+type ReloadReport struct {
+	Time    time.Time `json:"time"`
+	Forced  bool      `json:"forced"`
+	Hash    string    `json:"hash"`            // content hash of config source
+	Changed []string  `json:"changed"`         // paths of changed keys, e.g. "core.log.level"
+	Error   string    `json:"error,omitempty"` // reloading error, the old config kept
+}
+
+// LastReload returns report of the last reloading, nil returned if never reloaded
+func (app *BasicService) LastReload() *ReloadReport {
+	report, _ := app.config.last.Load().(*ReloadReport)
+	return report
+}
+
+// watchConfig requests reloading while the config file changed or SIGHUP received,
+// config is reloaded by Update.
+func (app *BasicService) watchConfig() {
+	if !app.config.canReload {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	app.config.cancel = cancel
+	changes := config.Watch(config.NewContext(ctx, app.Config()), app.Config().GetSource(), 2*time.Second)
+	go func() {
+		for range changes {
+			app.config.pending.CompareAndSwap(0, reloadChanged)
+		}
+	}()
+	signal.Register(syscall.SIGHUP, func(sig os.Signal) bool {
+		log.Info().String("signal", sig.String()).Print("service received signal, reloading config")
+		app.config.pending.Store(reloadForced)
+		return false
+	})
+}
+
+func (app *BasicService) reloadCfg(forced bool) {
+	if !app.config.canReload {
+		return
+	}
+	report, err := app.reload(forced)
+	if err != nil {
+		log.Warn().
+			Bool("forced", forced).
+			Error("error", err).
+			Print("reload config error")
+		return
+	}
+	if report != nil {
+		log.Info().
+			Bool("forced", forced).
+			String("hash", report.Hash).
+			Strings("changed", report.Changed).
+			Print("config reloaded")
+	}
+}
+
+// reload reads config from source and rewrites config of self, nil report
+// returned if content of config file not changed and not forced.
+func (app *BasicService) reload(forced bool) (report *ReloadReport, err error) {
+	rewriter, ok := app.self.(ConfigRewriter)
+	if !ok {
+		return nil, ErrReloadUnsupported
+	}
+	app.config.mu.Lock()
+	defer app.config.mu.Unlock()
+
+	cfg := app.config.ptr.Load().(config.Configurator)
+	hash, err := config.Hash(config.NewContext(context.Background(), cfg), cfg.GetSource())
+	if err != nil {
+		return nil, err
+	}
+	if hash == app.config.hash && !forced {
+		return nil, nil
+	}
+	report = &ReloadReport{
+		Time:   time.Now(),
+		Forced: forced,
+		Hash:   hash,
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			log.Error().Any("error", e).Print("reload config panicked")
+			err = fmt.Errorf("reload config panicked: %v", e)
+		}
+		if err != nil {
+			report.Error = err.Error()
+		}
+		app.config.last.Store(report)
+	}()
+
+	newCfg := cfg.Default()
+	newCfg.SetSource(cfg.GetSource())
+	config.SetOverrides(newCfg, config.OverridesOf(cfg))
+	if _, err := config.Load(newCfg, true); err != nil {
+		return report, err
+	}
+	if err := config.Validate(newCfg); err != nil {
+		return report, err
+	}
+	if report.Changed, err = config.Diff(cfg, newCfg); err != nil {
+		return report, err
+	}
+	newCfg.OnReload()
+	rewriter.RewriteConfig(unsafe.Pointer(reflect.ValueOf(newCfg).Pointer()))
+	app.config.ptr.Store(newCfg)
+	app.config.hash = hash
+	return report, nil
+}
@@ -3,11 +3,13 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"os"
-	"reflect"
 	"strconv"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 	"unsafe"
 
@@ -15,9 +17,12 @@ import (
 
 	"github.com/gopherd/doge/build"
 	"github.com/gopherd/doge/config"
+	_ "github.com/gopherd/doge/config/source/discoverysource"
+	_ "github.com/gopherd/doge/config/source/httpsource"
 	"github.com/gopherd/doge/erron"
 	"github.com/gopherd/doge/internal/uuid"
 	"github.com/gopherd/doge/mq"
+	"github.com/gopherd/doge/net/httputil"
 	"github.com/gopherd/doge/os/signal"
 	"github.com/gopherd/doge/service/discovery"
 	"github.com/gopherd/doge/service/module"
@@ -50,10 +55,12 @@ func (state State) String() string {
 type DiscoveryContent struct {
 	Config any `json:"config"` // config of service
 	State  struct {
-		Updated int64  `json:"updated"`
-		PID     int    `json:"pid"`   // process id
-		State   State  `json:"state"` // run state
-		UUID    string `json:"uuid"`  // instance uuid
+		Updated int64                          `json:"updated"`
+		PID     int                            `json:"pid"`               // process id
+		State   State                          `json:"state"`             // run state
+		UUID    string                         `json:"uuid"`              // instance uuid
+		Health  module.Health                  `json:"health"`            // aggregate health of modules
+		Modules map[string]module.HealthStatus `json:"modules,omitempty"` // health of modules by name
 	} `json:"state"` // runtime state of service
 }
 
@@ -85,6 +92,10 @@ type Service interface {
 	Shutdown() error
 }
 
+// ErrReloadUnsupported represents an error in case of reloading config of
+// service which doesn't implement ConfigRewriter
+var ErrReloadUnsupported = errors.New("service: config reload unsupported")
+
 // ConfigRewriter rewrites Config
 type ConfigRewriter interface {
 	RewriteConfig(unsafe.Pointer)
@@ -116,6 +127,29 @@ func Run(app Service) {
 	}
 }
 
+// exit codes of service
+const (
+	exitCodeDrainTimeout = 1 // busy service not drained before deadline
+	exitCodeForced       = 2 // exit forced by a second signal while stopping
+)
+
+// shutdownSignals are signals to shutdown the service
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT}
+
+// configurable represents a service which exposes its configuration
+type configurable interface {
+	Config() config.Configurator
+}
+
+// drainTimeout returns the max duration of waiting for busy app while stopping
+func drainTimeout(app Service) time.Duration {
+	var core config.CoreConfig
+	if c, ok := app.(configurable); ok {
+		core = *c.Config().GetCore()
+	}
+	return core.GetDrainTimeout()
+}
+
 func exec(app Service) error {
 	defer log.Shutdown()
 	if err := app.Init(); err != nil {
@@ -123,41 +157,98 @@ func exec(app Service) error {
 		return err
 	}
 	log.Info().Print("starting service")
-	app.Start()
+	if err := app.Start(); err != nil {
+		log.Info().Error("error", err).Print("app start error")
+		app.Shutdown()
+		return err
+	}
 	if err := app.SetState(Running); err != nil {
 		log.Info().Error("error", err).Print("set service state error")
 		app.Shutdown()
 		return err
 	}
 
-	// Waiting signal INT, you can kill the process via
+	// Waiting signal INT, TERM or QUIT, you can kill the process via
 	//
-	//	kill -s INT <pid>
+	//	kill -s TERM <pid>
 	//
 	// or Ctrl-C
-	signal.Register(os.Interrupt, func(os.Signal) bool {
-		return true
-	})
-	log.Info().Print("service started, Ctrl-C or run command 'kill -s INT <pid>' to shutdown the service")
+	for _, sig := range shutdownSignals {
+		signal.Register(sig, func(sig os.Signal) bool {
+			log.Info().String("signal", sig.String()).Print("service received signal")
+			return true
+		})
+	}
+	log.Info().Print("service started, Ctrl-C or run command 'kill -s TERM <pid>' to shutdown the service")
 	signal.Listen()
-	log.Info().Print("service received signal INT")
+
+	// A second signal forces the process to exit
+	return stop(app, drainTimeout(app), signal.Listen, func(code int) {
+		log.Shutdown()
+		os.Exit(code)
+	})
+}
+
+// stop stops the running app: waits for the busy app to drain in timeout
+// (0 means no limit), and then shuts it down. It calls exit with exitCodeForced
+// once wait returns before stopped. The exit error of exitCodeDrainTimeout
+// returned is joined with the error of Shutdown if drain timeout.
+func stop(app Service, timeout time.Duration, wait func(), exit func(code int)) error {
 	app.SetState(Stopping)
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		wait()
+		select {
+		case <-stopped:
+		default:
+			log.Warn().Print("service received signal again, force exiting")
+			exit(exitCodeForced)
+		}
+	}()
 
+	var exitErr error
 	if app.Busy() {
-		log.Info().Print("service busy now, maybe waiting for a while")
-		ticker := time.NewTicker(time.Millisecond * 100)
-		defer ticker.Stop()
-		for range ticker.C {
-			if !app.Busy() {
-				break
-			}
+		log.Info().
+			Duration("timeout", timeout).
+			Print("service busy now, maybe waiting for a while")
+		if !drain(app, timeout, 100*time.Millisecond) {
+			log.Error().
+				Duration("timeout", timeout).
+				Print("service still busy after drain timeout")
+			exitErr = config.ExitError(exitCodeDrainTimeout)
 		}
 	}
 
 	log.Info().Print("shutting down service")
 	app.SetState(Closed)
 
-	return app.Shutdown()
+	if err := app.Shutdown(); err != nil {
+		log.Error().Error("error", err).Print("app shutdown error")
+		return errors.Join(exitErr, err)
+	}
+	return exitErr
+}
+
+// drain polls the busy app every interval until it's idle, false returned if
+// it's still busy after timeout (0 means no limit).
+func drain(app Meta, timeout, interval time.Duration) bool {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for app.Busy() {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			return false
+		}
+	}
+	return true
 }
 
 // BasicService implements Service
@@ -166,21 +257,35 @@ type BasicService struct {
 	name  string
 	id    int64
 	uuid  string
-	state State
+	state atomic.Int32
 	force bool
 
 	config struct {
+		mu        sync.Mutex // guards reloading
 		ptr       atomic.Value
 		canReload bool
+		hash      string       // content hash of the loaded config file
+		pending   atomic.Int32 // pending reload request
+		last      atomic.Value // last ReloadReport
+		cancel    context.CancelFunc
 	}
 
 	discovery discovery.Discovery
 	mq        mq.Conn
 	modules   *module.Manager
+	loop      *Loop
+	admin     *httputil.HTTPServer
+
+	health struct {
+		mu      sync.RWMutex
+		health  module.Health
+		modules map[string]module.HealthStatus
+
+		quit, wait chan struct{}
+	}
 
 	tickers struct {
 		keepalive *timer.Ticker
-		reloadCfg *timer.Ticker
 	}
 }
 
@@ -194,7 +299,6 @@ func NewBasicService(self Service, cfg config.Configurator) *BasicService {
 	s.config.ptr.Store(cfg)
 	_, s.config.canReload = self.(ConfigRewriter)
 	s.tickers.keepalive = timer.NewTicker(time.Second * 3)
-	s.tickers.reloadCfg = timer.NewTicker(time.Second * 2)
 	return s
 }
 
@@ -203,6 +307,26 @@ func (app *BasicService) AddModule(mod module.Module) module.Module {
 	return app.modules.Add(mod)
 }
 
+// Post posts fn to run in the frame loop goroutine, false returned if the
+// frame loop disabled (core.loop.fps <= 0).
+func (app *BasicService) Post(fn func()) bool {
+	if app.loop == nil {
+		return false
+	}
+	app.loop.Post(fn)
+	return true
+}
+
+// Loop returns the built-in frame loop, nil returned if it's disabled
+func (app *BasicService) Loop() *Loop {
+	return app.loop
+}
+
+// Modules returns the module manager of service
+func (app *BasicService) Modules() *module.Manager {
+	return app.modules
+}
+
 // Name implements Service Name method
 func (app *BasicService) Name() string {
 	return app.name
@@ -225,15 +349,20 @@ func (app *BasicService) Busy() bool {
 
 // State returns state of service
 func (app *BasicService) State() State {
-	return app.state
+	return State(app.state.Load())
 }
 
 // SetState implements Service SetState method
 func (app *BasicService) SetState(state State) error {
-	app.state = state
+	app.state.Store(int32(state))
 	return app.register(state == Running)
 }
 
+// Config returns the current configuration
+func (app *BasicService) Config() config.Configurator {
+	return app.config.ptr.Load().(config.Configurator)
+}
+
 // Discovery returns the discovery engine
 func (app *BasicService) Discovery() discovery.Discovery {
 	return app.discovery
@@ -252,12 +381,15 @@ func (app *BasicService) register(nx bool) error {
 	now := time.Now().UnixNano() / 1e6
 	content.State.Updated = now
 	content.State.PID = pid
-	content.State.State = app.state
+	content.State.State = app.State()
+	content.State.Health, content.State.Modules = app.Health()
 	cfg := app.config.ptr.Load().(config.Configurator)
 	if d, ok := cfg.(config.Discoverable); ok {
 		content.Config = d.DiscoveredContent()
+	} else if masked, err := config.Mask(cfg); err != nil {
+		return err
 	} else {
-		content.Config = cfg
+		content.Config = masked
 	}
 	data, err := json.Marshal(content)
 	if err != nil {
@@ -316,34 +448,6 @@ func (app *BasicService) unregister() error {
 	return app.discovery.Unregister(context.Background(), app.Name(), strconv.FormatInt(app.ID(), 10))
 }
 
-func (app *BasicService) reloadCfg() {
-	if !app.config.canReload {
-		return
-	}
-	defer func() {
-		if e := recover(); e != nil {
-			log.Error().Any("error", e).Print("reload config panicked")
-		}
-	}()
-
-	cfg := app.config.ptr.Load().(config.Configurator)
-	rewriter, ok := app.self.(ConfigRewriter)
-	if !ok {
-		app.config.canReload = false
-		return
-	}
-	newCfg := cfg.Default()
-	newCfg.SetSource(cfg.GetSource())
-	if err := config.Read(newCfg, true); err != nil {
-		log.Warn().Error("error", err).Print("reload config error")
-		return
-	}
-	newCfg.OnReload()
-	rewriter.RewriteConfig(unsafe.Pointer(reflect.ValueOf(newCfg).Pointer()))
-	app.config.ptr.Store(newCfg)
-	log.Trace().Print("reload config successfully")
-}
-
 // Init implements Service Init method
 func (app *BasicService) Init() error {
 	cfg := app.config.ptr.Load().(config.Configurator)
@@ -353,6 +457,9 @@ func (app *BasicService) Init() error {
 	if err != nil {
 		return erron.Throw(err)
 	}
+	if app.config.hash, err = config.Hash(config.NewContext(context.Background(), cfg), cfg.GetSource()); err != nil {
+		return erron.Throw(err)
+	}
 	core := cfg.GetCore()
 	app.id = core.ID
 	app.name = core.Name
@@ -415,20 +522,124 @@ func (app *BasicService) Init() error {
 		app.mq = q
 	}
 
-	return app.modules.Init()
+	// create the frame loop which drives Update of self
+	if core.Loop.FPS > 0 {
+		app.loop = NewLoop(app.update,
+			WithFPS(core.Loop.FPS),
+			WithSlowFrame(time.Duration(core.Loop.SlowFrame)*time.Millisecond),
+		)
+	}
+
+	// set timeouts of lifecycle phases of modules
+	app.modules.SetTimeout(module.PhaseInit, time.Duration(core.Module.InitTimeout)*time.Second)
+	app.modules.SetTimeout(module.PhaseStart, time.Duration(core.Module.StartTimeout)*time.Second)
+	app.modules.SetTimeout(module.PhaseShutdown, time.Duration(core.Module.ShutdownTimeout)*time.Second)
+
+	// start admin server, so that the instance could be inspected while
+	// initializing modules, it's shut down if modules failed to initialize
+	// since Shutdown won't be called then.
+	if core.Admin.Address != "" {
+		if err := app.startAdmin(core.Admin); err != nil {
+			return erron.Throw(err)
+		}
+	}
+
+	if err := app.modules.InitContext(context.Background()); err != nil {
+		app.shutdownAdmin()
+		return err
+	}
+	return nil
 }
 
 // Start implements Service Start method
 func (app *BasicService) Start() error {
-	app.modules.Start()
+	if err := app.modules.StartContext(context.Background()); err != nil {
+		return err
+	}
+	app.checkHealth()
+	app.health.quit = make(chan struct{})
+	app.health.wait = make(chan struct{})
+	go app.pollHealth(app.health.quit, app.health.wait)
+	app.watchConfig()
+	if app.loop != nil {
+		app.loop.Start()
+	}
 	return nil
 }
 
 // Shutdown implements Service Shutdown method
 func (app *BasicService) Shutdown() error {
-	app.modules.Shutdown()
+	if app.config.cancel != nil {
+		app.config.cancel()
+	}
+	if app.loop != nil {
+		app.loop.Shutdown()
+	}
+	if app.health.quit != nil {
+		close(app.health.quit)
+		<-app.health.wait
+		app.health.quit = nil
+	}
+	err := app.modules.ShutdownContext(context.Background())
 	app.unregister()
-	return nil
+	app.shutdownAdmin()
+	return err
+}
+
+// Health returns the aggregate health and health of modules by name,
+// which are checked periodically after the service started.
+func (app *BasicService) Health() (module.Health, map[string]module.HealthStatus) {
+	app.health.mu.RLock()
+	defer app.health.mu.RUnlock()
+	return app.health.health, app.health.modules
+}
+
+// checkHealth checks health of modules, and registers the service
+// immediately if the aggregate health changed.
+func (app *BasicService) checkHealth() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	health, modules := app.modules.CheckHealth(ctx)
+	app.health.mu.Lock()
+	changed := health != app.health.health
+	app.health.health = health
+	app.health.modules = modules
+	app.health.mu.Unlock()
+	if changed {
+		log.Info().String("health", health.String()).Print("service health changed")
+		if app.State() == Running {
+			app.register(false)
+		}
+	}
+}
+
+func (app *BasicService) pollHealth(quit, wait chan struct{}) {
+	defer close(wait)
+	ticker := time.NewTicker(app.tickers.keepalive.Interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			app.checkHealth()
+		case <-quit:
+			return
+		}
+	}
+}
+
+// updater represents a service which could be updated per frame
+type updater interface {
+	Update(now time.Time, dt time.Duration)
+}
+
+// update updates self per frame, so that Update overridden by the service
+// which embeds BasicService is called by the frame loop
+func (app *BasicService) update(now time.Time, dt time.Duration) {
+	if u, ok := app.self.(updater); ok {
+		u.Update(now, dt)
+	} else {
+		app.Update(now, dt)
+	}
 }
 
 // Update updates per frame
@@ -437,7 +648,7 @@ func (app *BasicService) Update(now time.Time, dt time.Duration) {
 	if app.tickers.keepalive.Next(now) {
 		app.register(false)
 	}
-	if app.tickers.reloadCfg.Next(now) {
-		app.reloadCfg()
+	if pending := app.config.pending.Swap(0); pending != 0 {
+		app.reloadCfg(pending == reloadForced)
 	}
 }
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gopherd/doge/config"
+)
+
+// drainService is busy until idle is set
+type drainService struct {
+	idle     atomic.Bool
+	state    atomic.Int32
+	shutdown error
+}
+
+func (s *drainService) ID() int64    { return 1 }
+func (s *drainService) Name() string { return "drain" }
+func (s *drainService) UUID() string { return "drain" }
+func (s *drainService) Busy() bool   { return !s.idle.Load() }
+func (s *drainService) State() State { return State(s.state.Load()) }
+func (s *drainService) Init() error  { return nil }
+func (s *drainService) Start() error { return nil }
+func (s *drainService) Shutdown() error {
+	return s.shutdown
+}
+
+func (s *drainService) SetState(state State) error {
+	s.state.Store(int32(state))
+	return nil
+}
+
+func TestDrain(t *testing.T) {
+	s := new(drainService)
+	if drain(s, 20*time.Millisecond, time.Millisecond) {
+		t.Fatal("want drain timeout")
+	}
+	time.AfterFunc(10*time.Millisecond, func() { s.idle.Store(true) })
+	if !drain(s, 0, time.Millisecond) {
+		t.Fatal("want drained")
+	}
+}
+
+func TestStop(t *testing.T) {
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	never := func() { <-done }
+	noexit := func(code int) { t.Errorf("unexpected exit with code %d", code) }
+
+	s := new(drainService)
+	s.idle.Store(true)
+	if err := stop(s, time.Second, never, noexit); err != nil || s.State() != Closed {
+		t.Fatalf("want stopped without error, got %v, state %v", err, s.State())
+	}
+
+	s = new(drainService)
+	err := stop(s, 20*time.Millisecond, never, noexit)
+	if code, ok := config.IsExitError(err); !ok || code != exitCodeDrainTimeout {
+		t.Fatalf("want exit code %d, got %v", exitCodeDrainTimeout, err)
+	}
+
+	s = new(drainService)
+	s.shutdown = errors.New("shutdown error")
+	err = stop(s, 20*time.Millisecond, never, noexit)
+	if code, ok := config.IsExitError(err); !ok || code != exitCodeDrainTimeout || !errors.Is(err, s.shutdown) {
+		t.Fatalf("want exit code %d with shutdown error, got %v", exitCodeDrainTimeout, err)
+	}
+
+	s = new(drainService)
+	signals := make(chan struct{})
+	exited := make(chan int, 1)
+	go func() {
+		stop(s, 0, func() { <-signals }, func(code int) {
+			exited <- code
+			s.idle.Store(true)
+		})
+	}()
+	close(signals) // the second signal
+	select {
+	case code := <-exited:
+		if code != exitCodeForced {
+			t.Fatalf("want exit code %d, got %d", exitCodeForced, code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("not forced to exit")
+	}
+}
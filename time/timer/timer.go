@@ -6,6 +6,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/gopherd/doge/metrics"
 )
 
 const Forever = -1
@@ -201,6 +203,13 @@ func (s *memoryScheduler) schedule() {
 	}
 }
 
+// queueLen returns the number of pending operations in queue
+func (s *memoryScheduler) queueLen() int {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.queue.Len()
+}
+
 // Add implements Scheduler Add method
 func (s *memoryScheduler) Add(next, duration time.Duration, task Task, times int) ID {
 	id := ID(atomic.AddInt64(&s.nextId, 1))
@@ -333,6 +342,13 @@ var globalScheduler = NewMemoryScheduler()
 
 func init() {
 	globalScheduler.Start()
+	metrics.MustRegister(metrics.NewGaugeFunc(
+		"doge_timer_queue_length",
+		"Number of pending operations in queue of the global timer scheduler",
+		func() float64 {
+			return float64(globalScheduler.(*memoryScheduler).queueLen())
+		},
+	))
 }
 
 // SetTimeout add a timeout timer
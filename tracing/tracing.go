@@ -0,0 +1,348 @@
+// Package tracing implements lightweight distributed tracing with W3C
+// trace context propagation.
+//
+//	ctx, span := tracing.Start(ctx, "login")
+//	defer span.End()
+//	span.SetAttribute("user", name)
+//
+// Span contexts are propagated over HTTP by the traceparent header (see
+// Inject and Extract), and over mq and proto sessions by their envelopes.
+// Finished spans are exported to the Exporter of the Tracer.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInvalidTraceparent represents an error in case of parsing malformed traceparent
+var ErrInvalidTraceparent = errors.New("tracing: invalid traceparent")
+
+// TraceparentHeader is the http header name of W3C trace context
+const TraceparentHeader = "traceparent"
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// IsValid reports whether the id is not all zeros
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span
+type SpanID [8]byte
+
+// IsValid reports whether the id is not all zeros
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext represents the propagated part of a span
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both trace id and span id are valid
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent encodes the span context as W3C traceparent:
+//
+//	00-<trace-id>-<span-id>-<flags>
+func (sc SpanContext) Traceparent() string {
+	var buf [55]byte
+	buf[0], buf[1], buf[2] = '0', '0', '-'
+	hex.Encode(buf[3:35], sc.TraceID[:])
+	buf[35] = '-'
+	hex.Encode(buf[36:52], sc.SpanID[:])
+	buf[52], buf[53], buf[54] = '-', '0', '0'
+	if sc.Sampled {
+		buf[54] = '1'
+	}
+	return string(buf[:])
+}
+
+// ParseTraceparent parses W3C traceparent, fields appended by future
+// versions are ignored.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+	var version [1]byte
+	if _, err := hex.Decode(version[:], []byte(s[0:2])); err != nil || version[0] == 0xff {
+		return sc, ErrInvalidTraceparent
+	}
+	if version[0] == 0 && len(s) != 55 {
+		return sc, ErrInvalidTraceparent
+	} else if len(s) > 55 && s[55] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceID[:], []byte(s[3:35])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(s[36:52])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(flags[:], []byte(s[53:55])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	if !sc.IsValid() {
+		return sc, ErrInvalidTraceparent
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+// Span represents an operation of a trace
+type Span struct {
+	tracer *Tracer
+	name   string
+	sc     SpanContext
+	parent SpanID
+	start  time.Time
+	end    time.Time
+	ended  int32
+
+	mu         sync.Mutex
+	attributes map[string]string
+	err        error
+}
+
+// Name returns name of the span
+func (s *Span) Name() string { return s.name }
+
+// Context returns the span context
+func (s *Span) Context() SpanContext { return s.sc }
+
+// Parent returns the span id of parent, it's invalid for root spans
+func (s *Span) Parent() SpanID { return s.parent }
+
+// StartTime returns the start time of span
+func (s *Span) StartTime() time.Time { return s.start }
+
+// EndTime returns the end time of span, it's zero before ended
+func (s *Span) EndTime() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.end
+}
+
+// SetAttribute sets an attribute of span
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]string)
+	}
+	s.attributes[key] = value
+}
+
+// Attributes returns a copy of attributes
+func (s *Span) Attributes() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attributes := make(map[string]string, len(s.attributes))
+	for k, v := range s.attributes {
+		attributes[k] = v
+	}
+	return attributes
+}
+
+// SetError records err of the operation
+func (s *Span) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Err returns the recorded error
+func (s *Span) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// End ends the span and exports it if sampled, only the first call takes effect.
+func (s *Span) End() {
+	if !atomic.CompareAndSwapInt32(&s.ended, 0, 1) {
+		return
+	}
+	s.mu.Lock()
+	s.end = time.Now()
+	s.mu.Unlock()
+	if s.sc.Sampled {
+		s.tracer.exporter().Export(s)
+	}
+}
+
+// Exporter exports finished spans, it MUST be concurrent-safe
+type Exporter interface {
+	Export(span *Span)
+}
+
+// ExporterFunc wraps function as an Exporter
+type ExporterFunc func(span *Span)
+
+// Export implements Exporter Export method
+func (fn ExporterFunc) Export(span *Span) { fn(span) }
+
+type nopExporter struct{}
+
+func (nopExporter) Export(*Span) {}
+
+// MemoryExporter keeps exported spans in memory, it's useful for tests
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// NewMemoryExporter creates a MemoryExporter
+func NewMemoryExporter() *MemoryExporter {
+	return new(MemoryExporter)
+}
+
+// Export implements Exporter Export method
+func (e *MemoryExporter) Export(span *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns exported spans in order of ended
+func (e *MemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+// Reset removes all exported spans
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
+
+// Tracer creates spans and exports them to the exporter
+type Tracer struct {
+	value atomic.Value // Exporter
+}
+
+type exporterHolder struct {
+	Exporter
+}
+
+// NewTracer creates a Tracer, spans are dropped if exporter is nil.
+func NewTracer(exporter Exporter) *Tracer {
+	t := new(Tracer)
+	t.SetExporter(exporter)
+	return t
+}
+
+// SetExporter replaces the exporter of tracer
+func (t *Tracer) SetExporter(exporter Exporter) {
+	if exporter == nil {
+		exporter = nopExporter{}
+	}
+	t.value.Store(exporterHolder{exporter})
+}
+
+func (t *Tracer) exporter() Exporter {
+	return t.value.Load().(exporterHolder).Exporter
+}
+
+// Start starts a span as a child of span context in ctx, a new trace is
+// started if ctx carries no span context.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	s := &Span{
+		tracer: t,
+		name:   name,
+		start:  time.Now(),
+	}
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		s.sc.TraceID = parent.TraceID
+		s.sc.Sampled = parent.Sampled
+		s.parent = parent.SpanID
+	} else {
+		rand.Read(s.sc.TraceID[:])
+		s.sc.Sampled = true
+	}
+	rand.Read(s.sc.SpanID[:])
+	return context.WithValue(ctx, contextKey{}, s), s
+}
+
+// DefaultTracer is the tracer used by package level functions, spans are
+// dropped until an exporter set.
+var DefaultTracer = NewTracer(nil)
+
+// SetExporter replaces the exporter of DefaultTracer
+func SetExporter(exporter Exporter) {
+	DefaultTracer.SetExporter(exporter)
+}
+
+// Start starts a span by DefaultTracer
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return DefaultTracer.Start(ctx, name)
+}
+
+// contextKey is the key of the span or remote span context in context
+type contextKey struct{}
+
+// SpanFromContext returns the span in ctx, nil returned if not found
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(contextKey{}).(*Span)
+	return s
+}
+
+// ContextWithRemote returns a copy of ctx carrying the span context
+// propagated from remote, it's used as parent of spans started from ctx.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context of the span or the remote
+// span context in ctx, whichever is attached later.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	switch v := ctx.Value(contextKey{}).(type) {
+	case *Span:
+		return v.sc
+	case SpanContext:
+		return v
+	default:
+		return SpanContext{}
+	}
+}
+
+// Inject sets the traceparent header by span context in ctx
+func Inject(ctx context.Context, header http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		header.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
+
+// Extract returns a copy of ctx carrying the remote span context parsed
+// from the traceparent header, ctx returned if no valid traceparent.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if sc, err := ParseTraceparent(header.Get(TraceparentHeader)); err == nil {
+		return ContextWithRemote(ctx, sc)
+	}
+	return ctx
+}
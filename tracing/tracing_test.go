@@ -0,0 +1,90 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gopherd/doge/tracing"
+)
+
+func TestTraceparent(t *testing.T) {
+	const s = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := tracing.ParseTraceparent(s)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if !sc.Sampled || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected span context: %+v", sc)
+	}
+	if got := sc.Traceparent(); got != s {
+		t.Fatalf("want %s, got %s", s, got)
+	}
+	if _, err := tracing.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future"); err != nil {
+		t.Fatalf("future version should be accepted: %v", err)
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		if _, err := tracing.ParseTraceparent(bad); !errors.Is(err, tracing.ErrInvalidTraceparent) {
+			t.Errorf("%q: want ErrInvalidTraceparent, got %v", bad, err)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	exporter := tracing.NewMemoryExporter()
+	tracer := tracing.NewTracer(exporter)
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.SetAttribute("k", "v")
+	child.SetError(errors.New("failed"))
+	child.End()
+	child.End()
+	root.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 || spans[0] != child || spans[1] != root {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+	if child.Context().TraceID != root.Context().TraceID || child.Parent() != root.Context().SpanID {
+		t.Errorf("child is not linked to root")
+	}
+	if root.Parent().IsValid() {
+		t.Errorf("root should have no parent")
+	}
+	if child.Attributes()["k"] != "v" || child.Err() == nil || child.EndTime().IsZero() {
+		t.Errorf("unexpected child: %v %v %v", child.Attributes(), child.Err(), child.EndTime())
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	exporter := tracing.NewMemoryExporter()
+	tracer := tracing.NewTracer(exporter)
+
+	ctx, span := tracer.Start(context.Background(), "client")
+	header := make(http.Header)
+	tracing.Inject(ctx, header)
+
+	remote := tracing.Extract(context.Background(), header)
+	_, server := tracer.Start(remote, "server")
+	if server.Context().TraceID != span.Context().TraceID || server.Parent() != span.Context().SpanID {
+		t.Errorf("server span is not linked to client span")
+	}
+
+	// spans of unsampled traces are not exported
+	sc := span.Context()
+	sc.Sampled = false
+	_, unsampled := tracer.Start(tracing.ContextWithRemote(context.Background(), sc), "unsampled")
+	unsampled.End()
+	if n := len(exporter.Spans()); n != 0 {
+		t.Errorf("want no spans exported, got %d", n)
+	}
+}